@@ -0,0 +1,174 @@
+package canonical
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultStripParams are tracking and session parameters that never change
+// the content of a page
+var DefaultStripParams = []string{
+	"utm_*",
+	"gclid",
+	"fbclid",
+	"msclkid",
+	"mc_cid",
+	"mc_eid",
+	"sessionid",
+	"session_id",
+	"sid",
+	"phpsessid",
+	"jsessionid",
+	"aspsessionid*",
+}
+
+// defaultPorts are dropped from hosts since they are implied by the scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonicalizer rewrites URLs to a canonical form so that different spellings
+// of the same page are recognized as duplicates
+type Canonicalizer struct {
+	// stripParams are lowercase glob patterns of query parameter names to remove
+	stripParams []string
+}
+
+// New creates a Canonicalizer removing query parameters whose name matches
+// one of stripParams, case-insensitively. Patterns may use path.Match globs
+// such as "utm_*".
+func New(stripParams []string) *Canonicalizer {
+	c := &Canonicalizer{}
+	for _, param := range stripParams {
+		c.stripParams = append(c.stripParams, strings.ToLower(param))
+	}
+	return c
+}
+
+// Default creates a Canonicalizer stripping DefaultStripParams
+func Default() *Canonicalizer {
+	return New(DefaultStripParams)
+}
+
+// Canonicalize returns a canonical copy of u: lowercase scheme and host, no
+// default port, no fragment, dot segments resolved, denylisted parameters
+// removed and the remaining parameters sorted by name
+func (c *Canonicalizer) Canonicalize(u *url.URL) *url.URL {
+	canonical := *u
+	canonical.Scheme = strings.ToLower(canonical.Scheme)
+	canonical.Host = canonicalHost(canonical.Scheme, canonical.Host)
+	canonical.Fragment = ""
+	canonical.RawFragment = ""
+
+	if canonical.Opaque == "" {
+		canonical.Path = c.canonicalPath(canonical.Path)
+		canonical.RawPath = ""
+	}
+	canonical.RawQuery = c.canonicalQuery(canonical.RawQuery)
+	canonical.ForceQuery = false
+	return &canonical
+}
+
+// Strip returns a copy of u without its fragment and denylisted query
+// parameters, leaving everything else as written. Unlike Canonicalize it
+// never changes which resource u points at, so the result can be fetched:
+// the path keeps its escaping and empty segments, and the parameters kept
+// are neither reordered nor re-encoded.
+func (c *Canonicalizer) Strip(u *url.URL) *url.URL {
+	stripped := *u
+	stripped.Fragment = ""
+	stripped.RawFragment = ""
+	if stripped.RawQuery == "" {
+		return &stripped
+	}
+	var kept []string
+	for _, param := range strings.Split(stripped.RawQuery, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !c.stripped(name) {
+			kept = append(kept, param)
+		}
+	}
+	stripped.RawQuery = strings.Join(kept, "&")
+	if stripped.RawQuery == "" {
+		stripped.ForceQuery = false
+	}
+	return &stripped
+}
+
+// Key returns the canonical form of u as a string, for use as a map key
+func (c *Canonicalizer) Key(u *url.URL) string {
+	return c.Canonicalize(u).String()
+}
+
+func canonicalHost(scheme string, host string) string {
+	host = strings.ToLower(host)
+	hostname, port := host, ""
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		hostname, port = host[:i], host[i+1:]
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	if port == "" || port == defaultPorts[scheme] {
+		return hostname
+	}
+	return hostname + ":" + port
+}
+
+func (c *Canonicalizer) canonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	// Session IDs are sometimes passed as path parameters, e.g. /page;jsessionid=123
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if j := strings.Index(segment, ";"); j >= 0 {
+			name, _, _ := strings.Cut(segment[j+1:], "=")
+			if c.stripped(name) {
+				segments[i] = segment[:j]
+			}
+		}
+	}
+	p = strings.Join(segments, "/")
+
+	cleaned := path.Clean(p)
+	if !strings.HasPrefix(cleaned, "/") {
+		cleaned = "/" + cleaned
+	}
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func (c *Canonicalizer) canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Leave queries that cannot be parsed untouched rather than lose parameters
+		return rawQuery
+	}
+	for name := range query {
+		if c.stripped(name) {
+			query.Del(name)
+		}
+	}
+	// Encode sorts parameters by name
+	return query.Encode()
+}
+
+func (c *Canonicalizer) stripped(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range c.stripParams {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
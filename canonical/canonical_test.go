@@ -0,0 +1,89 @@
+package canonical
+
+import (
+	"net/url"
+	"testing"
+)
+
+// TestCanonicalize tests each canonicalization step
+func TestCanonicalize(t *testing.T) {
+	c := Default()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Lowercase scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"Default http port", "http://example.com:80/a", "http://example.com/a"},
+		{"Default https port", "https://example.com:443/a", "https://example.com/a"},
+		{"Non-default port kept", "https://example.com:8443/a", "https://example.com:8443/a"},
+		{"Fragment removed", "https://example.com/a#section", "https://example.com/a"},
+		{"Empty path", "https://example.com", "https://example.com/"},
+		{"Dot segments", "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"Trailing slash kept", "https://example.com/a/b/../", "https://example.com/a/"},
+		{"Parameters sorted", "https://example.com/s?q=go&page=2", "https://example.com/s?page=2&q=go"},
+		{"Pagination kept", "https://example.com/list?page=2", "https://example.com/list?page=2"},
+		{"Tracking removed", "https://example.com/a?utm_source=x&UTM_Medium=y&id=1", "https://example.com/a?id=1"},
+		{"Session removed", "https://example.com/a?PHPSESSID=abc", "https://example.com/a"},
+		{"Path session removed", "https://example.com/a;jsessionid=abc", "https://example.com/a"},
+		{"Empty query dropped", "https://example.com/a?", "https://example.com/a"},
+		{"IPv6 host", "http://[::1]:80/a", "http://[::1]/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", tt.input, err)
+			}
+			before := u.String()
+			if got := c.Key(u); got != tt.expected {
+				t.Errorf("Canonicalize(%s) = %s, expected %s", tt.input, got, tt.expected)
+			}
+			if u.String() != before {
+				t.Errorf("Input URL should not be modified, got %s", u)
+			}
+		})
+	}
+}
+
+// TestStrip tests that only fragments and denylisted parameters are removed from fetched URLs
+func TestStrip(t *testing.T) {
+	c := Default()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Fragment removed", "https://example.com/a#section", "https://example.com/a"},
+		{"Tracking removed in order", "https://example.com/a?q=go&utm_source=x&page=2", "https://example.com/a?q=go&page=2"},
+		{"Escaped slash kept", "https://example.com/a%2Fb", "https://example.com/a%2Fb"},
+		{"Empty segment kept", "https://example.com/a//b", "https://example.com/a//b"},
+		{"Bare parameter kept", "https://example.com/a?flag", "https://example.com/a?flag"},
+		{"Escaping kept", "https://example.com/s?q=a+b%2Fc", "https://example.com/s?q=a+b%2Fc"},
+		{"Only tracking", "https://example.com/a?utm_source=x", "https://example.com/a"},
+		{"Escaped tracking name", "https://example.com/a?utm%5Fsource=x&id=1", "https://example.com/a?id=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.input)
+			if got := c.Strip(u).String(); got != tt.expected {
+				t.Errorf("Strip(%s) = %s, expected %s", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCanonicalizeCustomDenylist tests that only configured parameters are stripped
+func TestCanonicalizeCustomDenylist(t *testing.T) {
+	c := New([]string{"ref", "track*"})
+
+	u, _ := url.Parse("https://example.com/a?utm_source=x&ref=home&tracking=1&id=2")
+	expected := "https://example.com/a?id=2&utm_source=x"
+	if got := c.Key(u); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
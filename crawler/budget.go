@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons a crawl stops, reported by Crawler.StopReason
+const (
+	StopFrontierExhausted = "frontier exhausted"
+	StopTerminated        = "terminated"
+	StopMaxPages          = "max pages reached"
+	StopMaxBytes          = "max bytes reached"
+	StopMaxDuration       = "max duration reached"
+)
+
+// budget enforces the global page and byte limits of a crawl. Workers
+// reserve a page before fetching and record the bytes they downloaded.
+type budget struct {
+	maxPages int64
+	maxBytes int64
+	pages    atomic.Int64
+	bytes    atomic.Int64
+
+	// onExhausted is called once, with the reason, when a limit is reached
+	onExhausted func(reason string)
+	once        sync.Once
+}
+
+func newBudget(maxPages int, maxBytes int64, onExhausted func(reason string)) *budget {
+	return &budget{
+		maxPages:    int64(maxPages),
+		maxBytes:    maxBytes,
+		onExhausted: onExhausted,
+	}
+}
+
+// reserve claims a page before it is fetched, returning false once a limit
+// has been reached
+func (b *budget) reserve() bool {
+	if b.maxBytes > 0 && b.bytes.Load() >= b.maxBytes {
+		b.exhausted(StopMaxBytes)
+		return false
+	}
+	if b.maxPages > 0 && b.pages.Add(1) > b.maxPages {
+		b.exhausted(StopMaxPages)
+		return false
+	}
+	return true
+}
+
+// record adds the size of a fetched page and stops the crawl as soon as the
+// last page or byte of the budget has been used
+func (b *budget) record(size int) {
+	if b.maxBytes > 0 && b.bytes.Add(int64(size)) >= b.maxBytes {
+		b.exhausted(StopMaxBytes)
+	}
+	if b.maxPages > 0 && b.pages.Load() >= b.maxPages {
+		b.exhausted(StopMaxPages)
+	}
+}
+
+func (b *budget) exhausted(reason string) {
+	b.once.Do(func() {
+		b.onExhausted(reason)
+	})
+}
+
+func (b *budget) String() string {
+	return fmt.Sprintf("%d pages, %d bytes", b.pages.Load(), b.bytes.Load())
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a whole fetch, reading the body included
+	DefaultTimeout = 30 * time.Second
+	// DefaultConnectTimeout bounds establishing a TCP connection
+	DefaultConnectTimeout = 10 * time.Second
+	// DefaultTLSHandshakeTimeout bounds the TLS handshake
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	// DefaultResponseHeaderTimeout bounds the wait for response headers once
+	// the request was sent
+	DefaultResponseHeaderTimeout = 15 * time.Second
+	// DefaultMaxIdleConnsPerHost is how many keep-alive connections are kept
+	// open per host between fetches
+	DefaultMaxIdleConnsPerHost = 4
+	// DefaultIdleConnTimeout is how long an unused keep-alive connection is kept
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// HTTPConfig configures the HTTP client fetching pages, robots.txt and
+// sitemaps. Zero values use the defaults above.
+type HTTPConfig struct {
+	Timeout               time.Duration
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	MaxIdleConnsPerHost   int
+	IdleConnTimeout       time.Duration
+	// Headers are sent with every request, the User-Agent comes from
+	// Config.UserAgent and Config.Contact
+	Headers http.Header
+}
+
+// ParseHeader parses a "Name: value" header
+func ParseHeader(spec string) (string, string, error) {
+	name, value, ok := strings.Cut(spec, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("header %q: expected Name: value", spec)
+	}
+	if strings.EqualFold(name, "User-Agent") {
+		return "", "", fmt.Errorf("header %q: set the user agent with its own option", spec)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+func orDefault[T int | time.Duration](value T, fallback T) T {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// newHTTPClient builds the client of a crawl, sending userAgent and
+// config.Headers with every request
+func newHTTPClient(config HTTPConfig, userAgent string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   orDefault(config.ConnectTimeout, DefaultConnectTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   orDefault(config.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: orDefault(config.ResponseHeaderTimeout, DefaultResponseHeaderTimeout),
+		MaxIdleConnsPerHost:   orDefault(config.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+		IdleConnTimeout:       orDefault(config.IdleConnTimeout, DefaultIdleConnTimeout),
+		ExpectContinueTimeout: time.Second,
+	}
+	headers := config.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("User-Agent", userAgent)
+	return &http.Client{
+		Transport: &headerTransport{base: transport, headers: headers},
+		Timeout:   orDefault(config.Timeout, DefaultTimeout),
+	}
+}
+
+// headerTransport adds headers to requests that do not set them already
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	return t.base.RoundTrip(req)
+}
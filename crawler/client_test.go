@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestParseHeader tests parsing "Name: value" headers
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		spec      string
+		name      string
+		value     string
+		expectErr bool
+	}{
+		{"Accept-Language: en", "Accept-Language", "en", false},
+		{"x-token:abc:def", "X-Token", "abc:def", false},
+		{"Cookie:  a=1; b=2 ", "Cookie", "a=1; b=2", false},
+		{"Empty:", "Empty", "", false},
+		{"no colon", "", "", true},
+		{": value", "", "", true},
+		{"Bad Name: value", "", "", true},
+		{"User-Agent: other", "", "", true},
+	}
+	for _, tt := range tests {
+		name, value, err := ParseHeader(tt.spec)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ParseHeader(%q): expected error %v, got %v", tt.spec, tt.expectErr, err)
+			continue
+		}
+		if name != tt.name || value != tt.value {
+			t.Errorf("ParseHeader(%q): expected %q=%q, got %q=%q", tt.spec, tt.name, tt.value, name, value)
+		}
+	}
+}
+
+// TestCrawlerHTTPClient tests that every request identifies the crawler and a hung server times out
+func TestCrawlerHTTPClient(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := map[string]string{}
+	languages := map[string]string{}
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.URL.Path] = r.UserAgent()
+		languages[r.URL.Path] = r.Header.Get("Accept-Language")
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nAllow: /\n"))
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/hang">Hang</a></body></html>`))
+		case "/hang":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     1,
+		PolitenessDelay: time.Millisecond,
+		RevisitDelay:    time.Hour,
+		UserAgent:       "test-bot/2.0",
+		Contact:         "https://example.org/bot",
+		HTTP: HTTPConfig{
+			Timeout: 200 * time.Millisecond,
+			Headers: http.Header{"Accept-Language": {"de"}},
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler hung on a server that never responds")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := "test-bot/2.0 (+https://example.org/bot)"
+	for _, path := range []string{"/robots.txt", "/", "/hang"} {
+		if userAgents[path] != expected {
+			t.Errorf("Expected %s to be fetched as %q, got %q", path, expected, userAgents[path])
+		}
+		if languages[path] != "de" {
+			t.Errorf("Expected %s to be fetched with Accept-Language de, got %q", path, languages[path])
+		}
+	}
+}
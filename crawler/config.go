@@ -1,10 +1,191 @@
 package crawler
 
-import "time"
+import (
+	"net/url"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/canonical"
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultUserAgent identifies the crawler to servers and robots.txt
+	DefaultUserAgent = "web-crawler/1.0"
+	// DefaultPolitenessDelay is the minimum time between two fetches to the same host
+	DefaultPolitenessDelay = 2 * time.Second
+)
 
 type Config struct {
-	MaxRedirects    int
-	RevisitDelay    time.Duration
-	WorkerCount     int
+	// Seeds are seed requests added alongside the initial URLs, with their
+	// own MaxDepth and Scope if set, see frontier.Request
+	Seeds []*frontier.Request
+	// MaxDepth is the number of links followed from a seed, 0 means unlimited
+	MaxDepth int
+	// MaxRedirects is the most redirects followed for a page,
+	// DefaultMaxRedirects if zero and none if negative. Redirect targets
+	// must pass the frontier's scope rules and filters.
+	MaxRedirects int
+	// RevisitDelay is the default time before a URL may be crawled again
+	RevisitDelay time.Duration
+	// RevisitRules override RevisitDelay for matching hosts and paths
+	RevisitRules []frontier.RevisitRule
+	WorkerCount  int
+	// ExcludePatterns and IncludePatterns are scope rules, see frontier.ParseRule
 	ExcludePatterns []string
+	IncludePatterns []string
+	// Scope restricts links relative to their seed, frontier.ScopeAny if empty
+	Scope frontier.ScopeKind
+	// UserAgent is sent with every request and matched against robots.txt
+	// groups, DefaultUserAgent if empty
+	UserAgent string
+	// Contact is a URL or email address added to the user agent so site
+	// owners can reach whoever runs the crawl
+	Contact string
+	// HTTP configures timeouts, connection pooling and extra headers
+	HTTP HTTPConfig
+	// Fetcher replaces the HTTP client configured by HTTP, UserAgent and
+	// Contact, it must then send its own headers
+	Fetcher Fetcher
+	// Middleware wraps the fetcher, the first middleware sees each request first
+	Middleware []Middleware
+	// IgnoreRobots disables robots.txt checks
+	IgnoreRobots bool
+	// RobotsRetryDelay is how long requests to a host wait when its
+	// robots.txt could not be fetched before it is fetched again,
+	// robots.DefaultFailureTTL if zero
+	RobotsRetryDelay time.Duration
+	// PolitenessDelay is the minimum time between fetches to one host,
+	// DefaultPolitenessDelay if zero. A longer robots.txt Crawl-delay wins.
+	PolitenessDelay time.Duration
+	// MaxConnectionsPerHost is the most concurrent fetches to one host,
+	// 1 if zero
+	MaxConnectionsPerHost int
+	// MaxCrawlDelay caps robots.txt Crawl-delay values, unlimited if zero
+	MaxCrawlDelay time.Duration
+	// HostPolicies override the politeness settings of matching hosts, the
+	// first match wins
+	HostPolicies []HostPolicy
+	// Throttle adapts the delay of each host to its responses, disabled if zero
+	Throttle ThrottleConfig
+	// Sitemaps are sitemap or sitemap index URLs whose entries seed the crawl
+	Sitemaps []string
+	// DiscoverSitemaps also seeds from the Sitemap lines of each seed host's
+	// robots.txt, falling back to /sitemap.xml when there are none
+	DiscoverSitemaps bool
+	// StripParams are query parameter name globs removed when canonicalizing
+	// URLs, canonical.DefaultStripParams if nil
+	StripParams []string
+	// MaxPages stops the crawl after this many fetches, failed ones included.
+	// Zero means unlimited, as for the other budgets.
+	MaxPages int
+	// MaxBytes stops the crawl once this many response body bytes were downloaded
+	MaxBytes int64
+	// MaxDuration stops the crawl after this much wall-clock time
+	MaxDuration time.Duration
+	// MaxPagesPerHost is the most URLs queued for a single host
+	MaxPagesPerHost int
+	// Scorer orders the frontier, URLs are crawled in discovery order if nil
+	Scorer frontier.Scorer
+	// Queue and History persist the frontier, e.g. a frontier.DiskQueue and
+	// frontier.DiskHistory to make the crawl resumable. They are kept in
+	// memory if nil and closed when the crawl stops.
+	Queue   frontier.Queue
+	History frontier.History
+	// Traps limits URL shapes typical of crawler traps, disabled if zero
+	Traps frontier.TrapConfig
+	// Watch keeps the crawl running after the frontier is exhausted,
+	// revisiting every fetched page on an interval adapted to how often its
+	// content changes, bounded by Recrawl. It runs until terminated or a
+	// budget is exhausted. The revisit schedule is kept in RecrawlFile if
+	// set, so a resumed crawl keeps watching, and only in memory otherwise.
+	Watch       bool
+	Recrawl     frontier.RecrawlPolicy
+	RecrawlFile string
+	// Retry retries failed fetches, disabled if MaxAttempts is 1 or less
+	Retry RetryPolicy
+	// DeadLetters records the requests that failed for good, they are only
+	// logged if nil
+	DeadLetters DeadLetterStore
+	// Validators keeps the ETag and Last-Modified of fetched pages so
+	// revisits are conditional requests, in memory for the crawl if nil
+	Validators ValidatorStore
+	// Unconditional disables conditional requests, every fetch downloads the
+	// whole page
+	Unconditional bool
+}
+
+func (c *Config) canonicalizer() *canonical.Canonicalizer {
+	if c.StripParams == nil {
+		return canonical.Default()
+	}
+	return canonical.New(c.StripParams)
+}
+
+func (c *Config) userAgent() string {
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	if c.Contact != "" {
+		userAgent += " (+" + c.Contact + ")"
+	}
+	return userAgent
+}
+
+func (c *Config) maxRedirects() int {
+	switch {
+	case c.MaxRedirects < 0:
+		return 0
+	case c.MaxRedirects == 0:
+		return DefaultMaxRedirects
+	}
+	return c.MaxRedirects
+}
+
+func (c *Config) politenessDelay() time.Duration {
+	if c.PolitenessDelay > 0 {
+		return c.PolitenessDelay
+	}
+	return DefaultPolitenessDelay
+}
+
+// hostPolicy returns the politeness settings that apply to the host of u,
+// with defaults filled in
+func (c *Config) hostPolicy(u *url.URL) HostPolicy {
+	policy := HostPolicy{}
+	for _, candidate := range c.HostPolicies {
+		if candidate.matches(u) {
+			policy = candidate
+			break
+		}
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = c.politenessDelay()
+	}
+	if policy.MaxConnections <= 0 {
+		policy.MaxConnections = max(c.MaxConnectionsPerHost, 1)
+	}
+	if policy.MaxCrawlDelay == 0 {
+		policy.MaxCrawlDelay = c.MaxCrawlDelay
+	}
+	return policy
+}
+
+// scope builds the frontier scope from Scope and IncludePatterns, invalid
+// patterns are logged and ignored
+func (c *Config) scope() frontier.Scope {
+	scope := frontier.Scope{Kind: c.Scope}
+	if scope.Kind == "" {
+		scope.Kind = frontier.ScopeAny
+	}
+	for _, pattern := range c.IncludePatterns {
+		rule, err := frontier.ParseRule(pattern)
+		if err != nil {
+			log.Errorf("Ignoring include pattern: %s", err)
+			continue
+		}
+		scope.Include = append(scope.Include, rule)
+	}
+	return scope
 }
@@ -1,10 +1,17 @@
 package crawler
 
 import (
+	"bytes"
+	"net/http"
 	"net/url"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/Fardin-E/web_crawler.git/canonical"
 	"github.com/Fardin-E/web_crawler.git/frontier"
 	"github.com/Fardin-E/web_crawler.git/parser"
+	"github.com/Fardin-E/web_crawler.git/robots"
 	"github.com/Fardin-E/web_crawler.git/storage"
 
 	log "github.com/sirupsen/logrus"
@@ -12,95 +19,370 @@ import (
 
 type Crawler struct {
 	config         *Config
+	seeds          []url.URL
 	frontier       *frontier.Frontier
 	storage        storage.Storage
 	contentParsers []parser.Parser
-	deadLetter     chan *url.URL
+	deadLetter     chan *frontier.Request
 	processors     []Processor
+	// robots is nil when robots.txt is ignored
+	robots *robots.Cache
+	canon  *canonical.Canonicalizer
+	budget *budget
+	// conditional is nil when conditional requests are disabled
+	conditional *conditional
+	// fetcher fetches pages, client sends robots.txt and sitemap requests
+	// through it
+	fetcher Fetcher
+	client  *http.Client
+
+	stopMu     sync.Mutex
+	stopReason string
+
+	schedulerMu sync.Mutex
+	scheduler   *hostScheduler
 }
 
 func NewCrawler(initialUrls []url.URL,
 	contentStorage storage.Storage,
 	config *Config) *Crawler {
-	deadLetter := make(chan *url.URL, 100) // Buffered channel to prevent blocking
+	deadLetter := make(chan *frontier.Request, 100) // Buffered channel to prevent blocking
 	contentParser := []parser.Parser{&parser.HtmlParser{}}
-	return &Crawler{
-		frontier:       frontier.NewFrontier(initialUrls, config.ExcludePatterns),
+	c := &Crawler{
+		seeds:          slices.Clone(initialUrls),
 		storage:        contentStorage,
 		contentParsers: contentParser,
 		deadLetter:     deadLetter,
 		config:         config,
+		canon:          config.canonicalizer(),
+	}
+	fetcher := config.Fetcher
+	if fetcher == nil {
+		fetcher = NewHTTPFetcher(newHTTPClient(config.HTTP, config.userAgent()))
+	}
+	c.fetcher = Chain(fetcher, config.Middleware...)
+	c.client = &http.Client{Transport: fetcherTransport{fetcher: c.fetcher}}
+	c.budget = newBudget(config.MaxPages, config.MaxBytes, c.stop)
+	if !config.Unconditional {
+		validators := config.Validators
+		if validators == nil {
+			validators = newMemoryValidators()
+		}
+		c.conditional = &conditional{store: validators, key: c.canon.Key}
+	}
+
+	frontierOptions := []frontier.Option{
+		frontier.WithScope(config.scope()),
+		frontier.WithCanonicalizer(c.canon),
+		frontier.WithMaxDepth(config.MaxDepth),
+		frontier.WithHostBudget(config.MaxPagesPerHost),
+		frontier.WithScorer(config.Scorer),
+		frontier.WithRevisitPolicy(frontier.RevisitPolicy{
+			Default: config.RevisitDelay,
+			Rules:   config.RevisitRules,
+		}),
+	}
+	if config.Traps != (frontier.TrapConfig{}) {
+		frontierOptions = append(frontierOptions, frontier.WithTrapDetection(config.Traps))
+	}
+	if config.Watch {
+		frontierOptions = append(frontierOptions, frontier.WithRecrawl(config.Recrawl))
+		if config.RecrawlFile != "" {
+			frontierOptions = append(frontierOptions, frontier.WithRecrawlFile(config.RecrawlFile))
+		}
+	}
+	if config.Queue != nil {
+		frontierOptions = append(frontierOptions, frontier.WithQueue(config.Queue))
+	}
+	if config.History != nil {
+		frontierOptions = append(frontierOptions, frontier.WithHistory(config.History))
+	}
+	if !config.IgnoreRobots {
+		c.robots = robots.NewCache(c.client, config.userAgent())
+		if config.RobotsRetryDelay > 0 {
+			c.robots.SetFailureTTL(config.RobotsRetryDelay)
+		}
+		frontierOptions = append(frontierOptions, frontier.WithFilter(&robotsFilter{cache: c.robots}))
 	}
+	c.frontier = frontier.NewFrontier(initialUrls, config.ExcludePatterns, frontierOptions...)
+	for _, seed := range config.Seeds {
+		c.frontier.Add(seed)
+		c.seeds = append(c.seeds, *seed.Url)
+	}
+	return c
+}
+
+// politenessDelay returns the minimum time between two fetches to the host
+// of u: the host's configured delay, or its robots.txt Crawl-delay up to
+// the configured maximum if that is longer
+func (c *Crawler) politenessDelay(u *url.URL) time.Duration {
+	policy := c.config.hostPolicy(u)
+	return max(policy.Delay, c.crawlDelay(u, policy))
 }
 
+// crawlDelay returns the robots.txt Crawl-delay of the host of u as far as
+// policy obeys it
+func (c *Crawler) crawlDelay(u *url.URL, policy HostPolicy) time.Duration {
+	if c.robots == nil || policy.MaxCrawlDelay < 0 {
+		return 0
+	}
+	// The scheduler asks while dispatching, it must not wait for a fetch.
+	// The frontier's robots filter fetched robots.txt when u was added.
+	crawlDelay := c.robots.CachedCrawlDelay(u)
+	if policy.MaxCrawlDelay > 0 {
+		crawlDelay = min(crawlDelay, policy.MaxCrawlDelay)
+	}
+	return crawlDelay
+}
+
+// minDelay returns the shortest delay throttling may reduce the host of u
+// to: the configured minimum, but never less than its robots.txt Crawl-delay
+func (c *Crawler) minDelay(u *url.URL) time.Duration {
+	if c.config.Throttle.MinDelay <= 0 {
+		return c.politenessDelay(u)
+	}
+	return max(c.config.Throttle.MinDelay, c.crawlDelay(u, c.config.hostPolicy(u)))
+}
+
+// HostStats returns the delay, queue and response statistics of each host
+// the crawl is fetching from, hosts with the longest delay first. It is
+// empty before Start.
+func (c *Crawler) HostStats() []HostStats {
+	c.schedulerMu.Lock()
+	scheduler := c.scheduler
+	c.schedulerMu.Unlock()
+	if scheduler == nil {
+		return nil
+	}
+	return scheduler.stats()
+}
+
+// maxConnections returns the most concurrent fetches allowed to the host of u
+func (c *Crawler) maxConnections(u *url.URL) int {
+	return c.config.hostPolicy(u).MaxConnections
+}
+
+// Start runs the crawl and returns once the frontier has no pending requests
+// left, a budget is exhausted or Terminate is called, after all processors
+// have finished. StopReason tells which.
 func (c *Crawler) Start() {
-	distributedInputs := make([]chan *url.URL, c.config.WorkerCount)
+	if c.config.MaxDuration > 0 {
+		timer := time.AfterFunc(c.config.MaxDuration, func() {
+			c.stop(StopMaxDuration)
+		})
+		defer timer.Stop()
+	}
+
+	c.seedFromSitemaps()
+
+	// Nothing was seeded, deferred or scheduled, there is no work that could
+	// ever arrive
+	if stats := c.frontier.Stats(); c.frontier.Pending() == 0 && stats.Deferred == 0 && stats.Scheduled == 0 {
+		c.frontier.Terminate()
+	}
+
+	// Workers share one input, the scheduler hands each request to whichever
+	// worker is idle once its host may be fetched
+	scheduler := newHostScheduler(c.politenessDelay, c.maxConnections)
+	scheduler.limit = schedulerBuffer * c.config.WorkerCount
+	if c.config.Throttle.MaxDelay > 0 {
+		scheduler.throttle = &throttle{config: c.config.Throttle, minDelay: c.minDelay}
+	}
+	c.schedulerMu.Lock()
+	c.scheduler = scheduler
+	c.schedulerMu.Unlock()
+	// Retries go back through the scheduler, their requests stay pending in
+	// the frontier until they succeed or are dead-lettered
+	retries := newRetrier(c.config.Retry, c.config.DeadLetters, c.deadLetter, scheduler.add)
+	input := make(chan *frontier.Request)
 	workersResults := make([]chan CrawlResult, c.config.WorkerCount)
 	done := make(chan struct{})
 
+	go scheduler.run(c.frontier.Get(), input)
 	for i := range c.config.WorkerCount {
-		distributedInputs[i] = make(chan *url.URL)
 		workersResults[i] = make(chan CrawlResult)
-	}
-	go distributeUrls(c.frontier, distributedInputs)
-	for i := range c.config.WorkerCount {
-		worker := NewWorker(distributedInputs[i], workersResults[i], done, i, c.deadLetter)
+		worker := NewWorker(input, workersResults[i], done, i, c.deadLetter)
+		// The scheduler already spaces out fetches to a host
+		worker.SetPoliteness(func(*url.URL) time.Duration { return 0 })
+		worker.SetFetcher(c.fetcher)
+		worker.maxRedirects = c.config.maxRedirects()
+		worker.checkRedirect = c.frontier.Redirect
+		worker.budget = c.budget
+		worker.scheduler = scheduler
+		worker.retrier = retries
+		worker.conditional = c.conditional
 		go worker.Start()
 	}
 
 	mergedResults := make(chan CrawlResult)
 	go mergeResults(workersResults, mergedResults)
-	newUrls := make(chan *url.URL)
-	c.AddProcessor(&LinkExtractor{NewUrls: newUrls})
-	c.AddProcessor(&SaveToFile{storageBackend: c.storage})
-	go func() {
-		for newUrl := range newUrls {
-			_ = c.frontier.Add(newUrl)
-		}
-	}()
+	c.AddProcessor(&LinkExtractor{Frontier: c.frontier, Canonicalizer: c.canon})
+	c.AddProcessor(&SaveToFile{storageBackend: c.storage, validators: c.conditional})
 
+	deadLetterDone := make(chan struct{})
 	go func() {
-		for deadUrl := range c.deadLetter {
-			log.Debugf("Dismissed %s", deadUrl)
+		defer close(deadLetterDone)
+		for deadReq := range c.deadLetter {
+			log.Debugf("Dismissed %s", deadReq)
+			c.frontier.Done(deadReq)
 		}
 	}()
 
+	// processing tracks processor goroutines so Start does not return while
+	// results are still being handled
+	var processing sync.WaitGroup
+
 	for result := range mergedResults {
-		// Parse once BEFORE passing to processors
-		for _, parser := range c.contentParsers {
-			if parser.IsSupportedExtension(result.ContentType) {
-				parsedInfo, err := parser.Parse(string(result.Body))
-				if err != nil {
-					log.Warnf("Failed to parse: %v", err)
-				} else {
-					result.Info = &parsedInfo
+		// Parse once BEFORE passing to processors. An unchanged page has no
+		// body, it keeps the parse stored from its last fetch so its links
+		// are still followed.
+		if result.NotModified {
+			result.Info = storedInfo(c.storage, &result)
+			if result.Info == nil {
+				c.forgetValidators(&result)
+			}
+		} else {
+			for _, parser := range c.contentParsers {
+				if parser.IsSupportedExtension(result.ContentType) {
+					parsedInfo, err := parser.Parse(string(result.Body))
+					if err != nil {
+						log.Warnf("Failed to parse: %v", err)
+					} else {
+						result.Info = &parsedInfo
+					}
+					break // Use only the first matching parser
 				}
-				break // Use only the first matching parser
 			}
 		}
 
+		var resultProcessing sync.WaitGroup
 		for _, processor := range c.processors {
+			resultProcessing.Add(1)
 			go func(processor Processor, result *CrawlResult) {
+				defer resultProcessing.Done()
 				if err := processor.Process(result); err != nil {
 					log.Error(err)
 				}
 			}(processor, &result)
 		}
+
+		// The request is only done once every processor, including the link
+		// extractor feeding the frontier, has finished with it
+		processing.Add(1)
+		go func(result *CrawlResult) {
+			defer processing.Done()
+			resultProcessing.Wait()
+			switch {
+			case result.NotModified:
+				c.frontier.NotModified(result.Request)
+			case result.Successful():
+				c.frontier.Visited(result.Request, recrawlContent(result))
+			}
+			c.frontier.Done(result.Request)
+		}(&result)
+	}
+
+	// Workers have all exited, nothing can be dead-lettered anymore
+	retries.stop()
+	close(c.deadLetter)
+	<-deadLetterDone
+	processing.Wait()
+	if err := c.frontier.Close(); err != nil {
+		log.Errorf("Failed to close the frontier: %s", err)
+	}
+
+	c.stopMu.Lock()
+	if c.stopReason == "" {
+		c.stopReason = StopFrontierExhausted
+	}
+	c.stopMu.Unlock()
+	log.WithField("reason", c.StopReason()).Println("Crawler exited")
+}
+
+// forgetValidators makes the next fetch of an unchanged page without a
+// stored copy download it again, so its links are not lost for good
+func (c *Crawler) forgetValidators(result *CrawlResult) {
+	if c.conditional == nil {
+		return
+	}
+	u := result.Url
+	if result.FinalUrl != nil {
+		u = result.FinalUrl
 	}
-	log.Println("Crawler exited")
+	if err := c.conditional.forget(u); err != nil {
+		log.WithField("url", u).Errorf("Failed to forget validators: %s", err)
+	}
+}
+
+// recrawlContent is the part of a page compared between visits to detect
+// changes: its text when it was parsed, so markup that differs on every
+// fetch does not count, otherwise the whole body
+func recrawlContent(result *CrawlResult) []byte {
+	if result.Info == nil {
+		return result.Body
+	}
+	var b bytes.Buffer
+	b.WriteString(result.Info.Title)
+	b.WriteByte(0)
+	b.WriteString(result.Info.Description)
+	for _, paragraph := range result.Info.Paragraphs {
+		b.WriteByte(0)
+		b.WriteString(paragraph)
+	}
+	return b.Bytes()
 }
 
 func (c *Crawler) Terminate() {
+	c.stop(StopTerminated)
+}
+
+// stop terminates the frontier, recording reason unless the crawl was
+// already stopping
+func (c *Crawler) stop(reason string) {
+	c.stopMu.Lock()
+	if c.stopReason == "" {
+		c.stopReason = reason
+		log.WithField("budget", c.budget).Infof("Stopping crawl: %s", reason)
+	}
+	c.stopMu.Unlock()
 	c.frontier.Terminate()
 }
+
+// StopReason returns why the crawl stopped, or an empty string while it is
+// still running
+func (c *Crawler) StopReason() string {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	return c.stopReason
+}
 func (c *Crawler) AddContentParser(contentParser parser.Parser) {
 	c.contentParsers = append(c.contentParsers, contentParser)
 }
 
 func (c *Crawler) AddExcludePattern(pattern string) {
+	if err := c.frontier.Exclude(pattern); err != nil {
+		log.Errorf("Ignoring exclude pattern: %s", err)
+		return
+	}
 	c.config.ExcludePatterns = append(c.config.ExcludePatterns, pattern)
 }
 
+// Rejected returns how many discovered URLs the frontier refused, by reason
+func (c *Crawler) Rejected() map[string]int {
+	return c.frontier.Rejected()
+}
+
+// Traps returns the crawler traps the frontier detected, with sample URLs
+func (c *Crawler) Traps() []frontier.TrapReport {
+	return c.frontier.Traps()
+}
+
+// FrontierStats returns a snapshot of the frontier: queued and in-flight
+// URLs, per-host queue sizes, the seen count and rejections
+func (c *Crawler) FrontierStats() frontier.Stats {
+	return c.frontier.Stats()
+}
+
 func (c *Crawler) AddProcessor(processor Processor) {
 	c.processors = append(c.processors, processor)
 }
@@ -5,9 +5,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Fardin-E/web_crawler.git/frontier"
 	"github.com/Fardin-E/web_crawler.git/storage"
 )
 
@@ -388,3 +392,480 @@ func BenchmarkCrawlerWorkerPool(b *testing.B) {
 		<-done
 	}
 }
+
+// TestCrawlerCompletesAutomatically tests that the crawl ends on its own once the site is exhausted
+func TestCrawlerCompletesAutomatically(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><body><a href="/a">A</a><a href="/b">B</a></body></html>`))
+		case "/a":
+			w.Write([]byte(`<html><body><a href="/b">B</a><a href="/missing">Missing</a></body></html>`))
+		case "/b":
+			w.Write([]byte(`<html><body><a href="/">Home</a></body></html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	serverURL, _ := url.Parse(server.URL)
+
+	config := &Config{
+		RevisitDelay: time.Hour,
+		WorkerCount:  3,
+	}
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, config)
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not complete on its own")
+	}
+	if reason := crawler.StopReason(); reason != StopFrontierExhausted {
+		t.Errorf("Expected stop reason %q, got %q", StopFrontierExhausted, reason)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/", "/a", "/b", "/missing"} {
+		if !fetched[path] {
+			t.Errorf("Expected %s to be fetched", path)
+		}
+	}
+}
+
+// TestCrawlerRespectsRobots tests that paths disallowed by robots.txt are never fetched
+func TestCrawlerRespectsRobots(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 0.1\n"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/private/secret">S</a><a href="/public">P</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	serverURL, _ := url.Parse(server.URL)
+
+	config := &Config{
+		RevisitDelay:    time.Hour,
+		WorkerCount:     2,
+		PolitenessDelay: 10 * time.Millisecond,
+	}
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, config)
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not complete")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetched["/private/secret"] {
+		t.Error("Path disallowed by robots.txt should not be fetched")
+	}
+	if !fetched["/public"] {
+		t.Error("Allowed path should be fetched")
+	}
+	if delay := crawler.politenessDelay(serverURL); delay != 100*time.Millisecond {
+		t.Errorf("Expected robots.txt crawl delay to raise politeness delay to 100ms, got %v", delay)
+	}
+}
+
+// TestCrawlerRobotsOutage tests that requests wait for a robots.txt that fails once instead of being dropped
+func TestCrawlerRobotsOutage(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path]++
+		robotsFetches := fetched["/robots.txt"]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			if robotsFetches == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/private/secret">S</a><a href="/public">P</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	serverURL, _ := url.Parse(server.URL)
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		RevisitDelay:     time.Hour,
+		WorkerCount:      1,
+		PolitenessDelay:  time.Millisecond,
+		RobotsRetryDelay: 100 * time.Millisecond,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not complete")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetched["/robots.txt"] != 2 {
+		t.Errorf("Expected robots.txt to be fetched again after failing, got %d fetches", fetched["/robots.txt"])
+	}
+	if fetched["/"] != 1 || fetched["/public"] != 1 {
+		t.Errorf("Expected the seed and its allowed link to be fetched once, got %v", fetched)
+	}
+	if fetched["/private/secret"] != 0 {
+		t.Error("Path disallowed by the recovered robots.txt should not be fetched")
+	}
+}
+
+// TestCrawlerSeedsFromSitemaps tests that pages only listed in a sitemap are crawled
+func TestCrawlerSeedsFromSitemaps(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("Sitemap: " + server.URL + "/sitemap.xml\n"))
+		case "/sitemap.xml":
+			w.Write([]byte(`<urlset><url><loc>` + server.URL + `/orphan</loc><changefreq>daily</changefreq></url></urlset>`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>No links here</body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	serverURL, _ := url.Parse(server.URL)
+
+	config := &Config{
+		RevisitDelay:     time.Hour,
+		WorkerCount:      2,
+		PolitenessDelay:  10 * time.Millisecond,
+		DiscoverSitemaps: true,
+	}
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, config)
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not complete")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !fetched["/orphan"] {
+		t.Error("Expected page listed only in the sitemap to be crawled")
+	}
+}
+
+// TestCrawlerBudgets tests that the crawl stops once a budget is exhausted
+func TestCrawlerBudgets(t *testing.T) {
+	var mu sync.Mutex
+	fetched := 0
+	// Every page links to two more pages, the crawl never runs out of work
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		fetched++
+		mu.Unlock()
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/?n=` + strconv.Itoa(2*n+1) + `">A</a><a href="/?n=` + strconv.Itoa(2*n+2) + `">B</a></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name       string
+		config     Config
+		reason     string
+		maxFetched int
+	}{
+		{"Max pages", Config{MaxPages: 3}, StopMaxPages, 3},
+		{"Max bytes", Config{MaxBytes: 1}, StopMaxBytes, 2},
+		{"Max duration", Config{MaxDuration: 300 * time.Millisecond}, StopMaxDuration, 0},
+		{"Max pages per host", Config{MaxPagesPerHost: 4, MaxDuration: 5 * time.Second}, StopFrontierExhausted, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			fetched = 0
+			mu.Unlock()
+
+			contentStorage, _ := storage.NewFileStorage(t.TempDir())
+			config := tt.config
+			config.RevisitDelay = time.Hour
+			config.WorkerCount = 2
+			config.PolitenessDelay = 10 * time.Millisecond
+			crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &config)
+
+			done := make(chan struct{})
+			go func() {
+				crawler.Start()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				crawler.Terminate()
+				t.Fatal("Crawler did not stop")
+			}
+
+			if reason := crawler.StopReason(); reason != tt.reason {
+				t.Errorf("Expected stop reason %q, got %q", tt.reason, reason)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.maxFetched > 0 && fetched > tt.maxFetched {
+				t.Errorf("Expected at most %d fetches, got %d", tt.maxFetched, fetched)
+			}
+		})
+	}
+}
+
+// TestCrawlerResume tests that a crawl stopped by a budget resumes from its disk frontier
+func TestCrawlerResume(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	// A chain of pages /0 -> /1 -> ... -> /5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		fetched[r.URL.Path]++
+		mu.Unlock()
+		n, _ := strconv.Atoi(r.URL.Path[1:])
+		w.Header().Set("Content-Type", "text/html")
+		if n < 5 {
+			w.Write([]byte(`<html><body><a href="/` + strconv.Itoa(n+1) + `">Next</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+	seedURL, _ := url.Parse(server.URL + "/0")
+	stateDir := t.TempDir()
+
+	crawl := func(maxPages int) *Crawler {
+		queue, err := frontier.NewDiskQueue(filepath.Join(stateDir, "queue"), 0)
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		history, err := frontier.NewDiskHistory(filepath.Join(stateDir, "seen.db"))
+		if err != nil {
+			t.Fatalf("Failed to open history: %v", err)
+		}
+		contentStorage, _ := storage.NewFileStorage(t.TempDir())
+		crawler := NewCrawler([]url.URL{*seedURL}, contentStorage, &Config{
+			RevisitDelay:    time.Hour,
+			WorkerCount:     1,
+			PolitenessDelay: 10 * time.Millisecond,
+			MaxPages:        maxPages,
+			Queue:           queue,
+			History:         history,
+		})
+
+		done := make(chan struct{})
+		go func() {
+			crawler.Start()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			crawler.Terminate()
+			t.Fatal("Crawler did not stop")
+		}
+		return crawler
+	}
+
+	if reason := crawl(2).StopReason(); reason != StopMaxPages {
+		t.Fatalf("Expected first run to stop with %q, got %q", StopMaxPages, reason)
+	}
+	if reason := crawl(0).StopReason(); reason != StopFrontierExhausted {
+		t.Errorf("Expected resumed run to finish with %q, got %q", StopFrontierExhausted, reason)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for n := range 6 {
+		if count := fetched["/"+strconv.Itoa(n)]; count != 1 {
+			t.Errorf("Expected /%d to be fetched once across both runs, got %d", n, count)
+		}
+	}
+}
+
+// TestCrawlerWatch tests that watch mode keeps revisiting pages, changing ones more often
+func TestCrawlerWatch(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		fetched[r.URL.Path]++
+		count := fetched[r.URL.Path]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/static" {
+			w.Write([]byte(`<html><body><p>Never changes</p></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body><p>Visit ` + strconv.Itoa(count) + `</p><a href="/static">Static</a></body></html>`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     2,
+		PolitenessDelay: 10 * time.Millisecond,
+		RevisitDelay:    100 * time.Millisecond,
+		MaxDuration:     1500 * time.Millisecond,
+		Watch:           true,
+		Recrawl:         frontier.RecrawlPolicy{MinInterval: 100 * time.Millisecond, MaxInterval: 2 * time.Second},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	if reason := crawler.StopReason(); reason != StopMaxDuration {
+		t.Errorf("Expected the watch to run until %q, got %q", StopMaxDuration, reason)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetched["/static"] < 2 {
+		t.Errorf("Expected the static page to be revisited, got %d fetches", fetched["/static"])
+	}
+	if fetched["/"] <= fetched["/static"]+2 {
+		t.Errorf("Expected the changing page to be revisited more often, got %d fetches against %d", fetched["/"], fetched["/static"])
+	}
+}
+
+// TestCrawlerErrorPages tests that error responses reach the processors but their links are not followed
+func TestCrawlerErrorPages(t *testing.T) {
+	var mu sync.Mutex
+	fetched := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/":
+			w.Write([]byte(`<html><body><a href="/gone">Gone</a></body></html>`))
+		default:
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`<html><body><a href="/hidden">Hidden</a></body></html>`))
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     1,
+		PolitenessDelay: time.Millisecond,
+		RevisitDelay:    time.Hour,
+	})
+	statuses := map[string]int{}
+	crawler.AddProcessor(&TestProcessor{callback: func(result *CrawlResult) error {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[result.Url.Path] = result.StatusCode
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if statuses[""] != http.StatusOK || statuses["/gone"] != http.StatusGone {
+		t.Errorf("Expected the seed with 200 and /gone with 410, got %v", statuses)
+	}
+	if fetched["/hidden"] {
+		t.Error("Links on an error page should not be followed")
+	}
+}
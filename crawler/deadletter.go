@@ -0,0 +1,147 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+)
+
+// DeadLetter is a request that could not be fetched, after its retries
+type DeadLetter struct {
+	Url      string             `json:"url"`
+	Parent   string             `json:"parent,omitempty"`
+	Seed     string             `json:"seed,omitempty"`
+	Depth    int                `json:"depth"`
+	MaxDepth int                `json:"max_depth,omitempty"`
+	Scope    frontier.ScopeKind `json:"scope,omitempty"`
+	Error    string             `json:"error"`
+	Class    ErrorClass         `json:"class"`
+	// Status is the response status code, 0 if there was no response
+	Status   int       `json:"status,omitempty"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func newDeadLetter(req *frontier.Request, err error, class ErrorClass, status int, attempts int) DeadLetter {
+	letter := DeadLetter{
+		Url:      req.Url.String(),
+		Depth:    req.Depth,
+		MaxDepth: req.MaxDepth,
+		Scope:    req.Scope,
+		Error:    err.Error(),
+		Class:    class,
+		Status:   status,
+		Attempts: attempts,
+		FailedAt: time.Now(),
+	}
+	if req.Parent != nil {
+		letter.Parent = req.Parent.String()
+	}
+	if req.Seed != nil {
+		letter.Seed = req.Seed.String()
+	}
+	return letter
+}
+
+// Request rebuilds the frontier request that failed, so it can be queued again
+func (d DeadLetter) Request() (*frontier.Request, error) {
+	u, err := url.Parse(d.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", d.Url, err)
+	}
+	req := frontier.NewRequest(u)
+	req.Depth = d.Depth
+	req.MaxDepth = d.MaxDepth
+	req.Scope = d.Scope
+	if d.Parent != "" {
+		if req.Parent, err = url.Parse(d.Parent); err != nil {
+			return nil, fmt.Errorf("invalid parent URL %q: %w", d.Parent, err)
+		}
+	}
+	if d.Seed != "" {
+		if req.Seed, err = url.Parse(d.Seed); err != nil {
+			return nil, fmt.Errorf("invalid seed URL %q: %w", d.Seed, err)
+		}
+	}
+	return req, nil
+}
+
+// DeadLetterStore records requests that failed for good
+type DeadLetterStore interface {
+	Record(letter DeadLetter) error
+}
+
+// DeadLetterFile appends dead letters to a JSONL file, one per line
+type DeadLetterFile struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenDeadLetterFile opens the dead-letter file at path for appending,
+// creating it if needed
+func OpenDeadLetterFile(path string) (*DeadLetterFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening dead-letter file: %w", err)
+	}
+	return &DeadLetterFile{file: file}, nil
+}
+
+func (f *DeadLetterFile) Record(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// One write per record keeps lines whole
+	if _, err := f.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing dead-letter file: %w", err)
+	}
+	return nil
+}
+
+func (f *DeadLetterFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// ReadDeadLetters reads the dead-letter file at path. A missing file holds
+// no dead letters.
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening dead-letter file: %w", err)
+	}
+	defer file.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading dead-letter file: %w", err)
+	}
+	return letters, nil
+}
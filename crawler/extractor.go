@@ -3,44 +3,117 @@ package crawler
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
+	"github.com/Fardin-E/web_crawler.git/canonical"
+	"github.com/Fardin-E/web_crawler.git/frontier"
 	log "github.com/sirupsen/logrus"
 )
 
+// LinkExtractor adds the links found on a page to the frontier, without
+// fragments and denylisted parameters. The frontier dedups them by their
+// canonical form.
 type LinkExtractor struct {
-	NewUrls chan *url.URL
+	Frontier *frontier.Frontier
+	// Canonicalizer defaults to canonical.Default() when nil
+	Canonicalizer *canonical.Canonicalizer
 }
 
 func (e *LinkExtractor) Process(result *CrawlResult) error {
+	// Links on error pages are mostly navigation of the error template
+	if !result.Successful() && !result.NotModified {
+		return nil
+	}
+	// An unchanged page without a stored copy has no known links
+	if result.NotModified && result.Info == nil {
+		return nil
+	}
 	if result.Info == nil {
 		return fmt.Errorf("no parsed Info available for URL: %s", result.Url)
 	}
 
+	canon := e.Canonicalizer
+	if canon == nil {
+		canon = canonical.Default()
+	}
+
+	base := baseUrl(result)
+	parent := result.Request
+	if parent == nil {
+		parent = frontier.NewRequest(result.Url)
+	}
+	if result.Info.Canonical != "" {
+		e.addCanonical(result, parent, base, canon)
+	}
+
 	foundUrls := make([]*url.URL, 0)
 	for _, parsedUrl := range result.Info.Links {
-		newUrl, err := url.Parse(parsedUrl.Value)
+		ref, err := url.Parse(strings.TrimSpace(parsedUrl.Value))
 		if err != nil {
 			log.Debugf("Error parsing url: %s", err)
 			continue
 		}
-		params := newUrl.Query()
-		for param := range params {
-			newUrl = stripQueryParam(newUrl, param)
-		}
+		newUrl := canon.Strip(base.ResolveReference(ref))
 		if newUrl.Scheme == "http" || newUrl.Scheme == "https" {
 			foundUrls = append(foundUrls, newUrl)
 		}
 	}
 	log.Infof("Extracted %d urls", len(foundUrls))
 	for _, foundUrl := range foundUrls {
-		e.NewUrls <- foundUrl
+		e.Frontier.Add(parent.Child(foundUrl))
 	}
 	return nil
 }
 
-func stripQueryParam(inputURL *url.URL, stripKey string) *url.URL {
-	query := inputURL.Query()
-	query.Del(stripKey)
-	inputURL.RawQuery = query.Encode()
-	return inputURL
+// addCanonical queues the page's <link rel="canonical"> target in place of
+// the page, so the preferred URL is crawled and later links to it are
+// duplicates. Only targets on the page's own host are honored, the frontier
+// checks them against the scope like any link, and a target already fetched
+// is left alone. Marking the target seen without fetching it would let any
+// page suppress any URL, e.g. every page of a list declaring the first.
+func (e *LinkExtractor) addCanonical(result *CrawlResult, parent *frontier.Request, base *url.URL, canon *canonical.Canonicalizer) {
+	ref, err := url.Parse(result.Info.Canonical)
+	if err != nil {
+		log.Debugf("Ignoring invalid canonical link %q: %s", result.Info.Canonical, err)
+		return
+	}
+	canonicalUrl := base.ResolveReference(ref)
+	if canonicalUrl.Scheme != "http" && canonicalUrl.Scheme != "https" {
+		return
+	}
+	canonicalUrl = canon.Strip(canonicalUrl)
+	if canon.Key(canonicalUrl) == canon.Key(result.Url) {
+		return
+	}
+	logger := log.WithFields(log.Fields{
+		"url":       result.Url,
+		"canonical": canonicalUrl,
+	})
+	if canon.Canonicalize(canonicalUrl).Host != canon.Canonicalize(base).Host {
+		logger.Debug("Ignoring canonical URL on another host")
+		return
+	}
+	logger.Debug("Page declares a different canonical URL")
+	// The canonical URL stands in for the page, at the page's depth
+	alternate := *parent
+	alternate.Url = canonicalUrl
+	e.Frontier.Add(&alternate)
+}
+
+// baseUrl returns the URL relative links of the result resolve against:
+// the document's <base href> if present, resolved against the URL the page
+// was finally served from after redirects.
+func baseUrl(result *CrawlResult) *url.URL {
+	base := result.Url
+	if result.FinalUrl != nil {
+		base = result.FinalUrl
+	}
+	if result.Info != nil && result.Info.Base != "" {
+		if baseHref, err := url.Parse(result.Info.Base); err == nil {
+			base = base.ResolveReference(baseHref)
+		} else {
+			log.Debugf("Ignoring invalid base href %q: %s", result.Info.Base, err)
+		}
+	}
+	return base
 }
@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/parser"
+)
+
+// TestLinkExtractorResolvesRelativeLinks tests that relative links are resolved against the page URL
+func TestLinkExtractorResolvesRelativeLinks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/docs/guide/intro.html")
+
+	tests := []struct {
+		name     string
+		finalUrl string
+		base     string
+		href     string
+		expected string
+	}{
+		{"Absolute path", "", "", "/page2", "https://example.com/page2"},
+		{"Relative path", "", "", "chapter1.html", "https://example.com/docs/guide/chapter1.html"},
+		{"Parent directory", "", "", "../api", "https://example.com/docs/api"},
+		{"Query only", "", "", "?p=2", "https://example.com/docs/guide/intro.html?p=2"},
+		{"Tracking params stripped", "", "", "/list?utm_source=x&page=2&sort=asc", "https://example.com/list?page=2&sort=asc"},
+		{"Protocol relative", "", "", "//cdn.example.com/lib", "https://cdn.example.com/lib"},
+		{"Absolute URL", "", "", "http://other.com/x", "http://other.com/x"},
+		{"Fragment dropped", "", "", "#top", "https://example.com/docs/guide/intro.html"},
+		{"Base href", "", "https://static.example.com/root/", "page", "https://static.example.com/root/page"},
+		{"Relative base href", "", "/v2/", "page", "https://example.com/v2/page"},
+		{"Final URL after redirect", "https://www.example.com/new/intro.html", "", "next", "https://www.example.com/new/next"},
+		{"Escaped slash kept", "", "", "/files/a%2Fb", "https://example.com/files/a%2Fb"},
+		{"Empty segment kept", "", "", "/a//b", "https://example.com/a//b"},
+		{"Bare parameter kept", "", "", "/search?flag", "https://example.com/search?flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := frontier.NewFrontier([]url.URL{}, []string{})
+			extractor := &LinkExtractor{Frontier: f}
+
+			result := &CrawlResult{
+				Url: pageURL,
+				Info: &parser.Info{
+					Base:  tt.base,
+					Links: []parser.Token{{Name: "link", Value: tt.href}},
+				},
+			}
+			if tt.finalUrl != "" {
+				result.FinalUrl, _ = url.Parse(tt.finalUrl)
+			}
+
+			if err := extractor.Process(result); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			select {
+			case got := <-f.Get():
+				if got.String() != tt.expected {
+					t.Errorf("Expected %s, got %s", tt.expected, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected link %q to be extracted", tt.href)
+			}
+		})
+	}
+}
+
+// TestLinkExtractorSkipsNonHTTPLinks tests that mailto, javascript and similar links are dropped
+func TestLinkExtractorSkipsNonHTTPLinks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/")
+	f := frontier.NewFrontier([]url.URL{}, []string{})
+	extractor := &LinkExtractor{Frontier: f}
+
+	result := &CrawlResult{
+		Url: pageURL,
+		Info: &parser.Info{
+			Links: []parser.Token{
+				{Name: "link", Value: "mailto:someone@example.com"},
+				{Name: "link", Value: "javascript:void(0)"},
+				{Name: "link", Value: "ftp://example.com/file"},
+			},
+		},
+	}
+
+	if err := extractor.Process(result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.Pending() != 0 {
+		t.Errorf("Expected no links to be extracted, got %d", f.Pending())
+	}
+}
+
+// TestLinkExtractorTracksDepth tests that extracted links carry depth and parent from the page request
+func TestLinkExtractorTracksDepth(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/page")
+	pageReq := frontier.NewRequest(pageURL).Child(pageURL)
+
+	f := frontier.NewFrontier([]url.URL{}, []string{})
+	extractor := &LinkExtractor{Frontier: f}
+	result := &CrawlResult{
+		Request: pageReq,
+		Url:     pageURL,
+		Info:    &parser.Info{Links: []parser.Token{{Name: "link", Value: "/next"}}},
+	}
+
+	if err := extractor.Process(result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := <-f.Get()
+	if got.Depth != 2 {
+		t.Errorf("Expected depth 2, got %d", got.Depth)
+	}
+	if got.Parent.String() != pageURL.String() {
+		t.Errorf("Expected parent %s, got %s", pageURL, got.Parent)
+	}
+}
+
+// TestLinkExtractorHonorsCanonicalLink tests that a same-host rel=canonical target is queued in place of the page
+func TestLinkExtractorHonorsCanonicalLink(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/product?id=7&ref=home")
+	f := frontier.NewFrontier([]url.URL{}, []string{"/private"})
+	defer f.Terminate()
+	extractor := &LinkExtractor{Frontier: f}
+
+	tests := []struct {
+		canonical string
+		queued    string
+	}{
+		{"/product?id=7", "https://example.com/product?id=7"},
+		// Pages pointing at one that was already fetched change nothing
+		{"/product?id=7", ""},
+		{"https://other.com/product/7", ""},
+		{"/private/product", ""},
+	}
+	for _, tt := range tests {
+		result := &CrawlResult{
+			Url:  pageURL,
+			Info: &parser.Info{Canonical: tt.canonical},
+		}
+		if err := extractor.Process(result); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		select {
+		case req := <-f.Get():
+			if req.Url.String() != tt.queued {
+				t.Errorf("Canonical %s: expected %q to be queued, got %s", tt.canonical, tt.queued, req.Url)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if tt.queued != "" {
+				t.Errorf("Canonical %s: expected %s to be queued", tt.canonical, tt.queued)
+			}
+		}
+	}
+
+	otherURL, _ := url.Parse("https://other.com/product/7")
+	if f.Seen(otherURL) {
+		t.Error("A canonical URL on another host should not be marked as seen")
+	}
+}
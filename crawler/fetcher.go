@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	log "github.com/sirupsen/logrus"
+)
+
+// Fetcher performs one HTTP request for the crawler. It must not follow
+// redirects: the worker follows them itself, so every hop is checked against
+// the crawl's scope. Pages, robots.txt files and sitemaps are all fetched
+// through it. The response needs a StatusCode and a Body, redirects a
+// Location header. Its Request is not used and may be nil.
+type Fetcher interface {
+	Fetch(req *http.Request) (*http.Response, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(req *http.Request) (*http.Response, error)
+
+func (f FetcherFunc) Fetch(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Fetcher to add behaviour such as logging, caching,
+// authentication or fault injection. As with http.RoundTripper, a
+// middleware must not modify the request it was given, but may pass on a
+// modified clone.
+type Middleware func(next Fetcher) Fetcher
+
+// Chain wraps fetcher in middleware, the first middleware sees each request first
+func Chain(fetcher Fetcher, middleware ...Middleware) Fetcher {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fetcher = middleware[i](fetcher)
+	}
+	return fetcher
+}
+
+// NewHTTPFetcher returns a Fetcher sending requests with client, http.DefaultClient if nil
+func NewHTTPFetcher(client *http.Client) Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return FetcherFunc(withoutRedirects(client).Do)
+}
+
+type requestKey struct{}
+
+// withRequest returns a context carrying the frontier request being fetched
+func withRequest(ctx context.Context, req *frontier.Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFromContext returns the frontier request an HTTP request fetches,
+// nil for robots.txt and sitemap fetches
+func RequestFromContext(ctx context.Context) *frontier.Request {
+	req, _ := ctx.Value(requestKey{}).(*frontier.Request)
+	return req
+}
+
+// fetcherTransport lets an http.Client send its requests through a Fetcher
+type fetcherTransport struct {
+	fetcher Fetcher
+}
+
+func (t fetcherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.fetcher.Fetch(req)
+}
+
+// LogFetches logs every request with its status and duration at debug level
+func LogFetches() Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.Fetch(req)
+			logger := log.WithFields(log.Fields{
+				"url":      req.URL,
+				"duration": time.Since(start),
+			})
+			if err != nil {
+				logger.Debugf("Fetch failed: %s", err)
+				return nil, err
+			}
+			logger.WithField("status", res.StatusCode).Debug("Fetched")
+			return res, nil
+		})
+	}
+}
+
+// InjectFaults answers a random fraction rate of requests with status
+// instead of fetching them, to exercise retries and throttling
+func InjectFaults(rate float64, status int) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(req *http.Request) (*http.Response, error) {
+			if rand.Float64() >= rate {
+				return next.Fetch(req)
+			}
+			body := fmt.Sprintf("injected fault: %d %s", status, http.StatusText(status))
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+				StatusCode:    status,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"text/plain"}},
+				Body:          io.NopCloser(strings.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		})
+	}
+}
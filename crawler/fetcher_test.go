@@ -0,0 +1,179 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestChain tests that middleware wraps the fetcher in order
+func TestChain(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next Fetcher) Fetcher {
+			return FetcherFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.Fetch(req)
+			})
+		}
+	}
+	fetcher := Chain(FetcherFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "fetcher")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), tag("first"), tag("second"))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if _, err := fetcher.Fetch(req); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "first,second,fetcher" {
+		t.Errorf("Expected first,second,fetcher, got %v", order)
+	}
+}
+
+// TestWorkerHandBuiltResponses tests that a fetcher may return responses without a Request
+func TestWorkerHandBuiltResponses(t *testing.T) {
+	input := make(chan *frontier.Request, 1)
+	result := make(chan CrawlResult, 1)
+	done := make(chan struct{})
+	defer close(done)
+	worker := NewWorker(input, result, done, 0, make(chan *frontier.Request, 1))
+	worker.SetFetcher(FetcherFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/old" {
+			return &http.Response{
+				StatusCode: http.StatusMovedPermanently,
+				Header:     http.Header{"Location": {"/new"}},
+				Body:       http.NoBody,
+			}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("New"))}, nil
+	}))
+	go worker.Start()
+
+	u, _ := url.Parse("https://example.com/old")
+	input <- frontier.NewRequest(u)
+	select {
+	case res := <-result:
+		if res.FinalUrl.String() != "https://example.com/new" || string(res.Body) != "New" {
+			t.Errorf("Expected the body of https://example.com/new, got %q from %s", res.Body, res.FinalUrl)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
+
+// TestInjectFaults tests that faults replace the response at the configured rate
+func TestInjectFaults(t *testing.T) {
+	ok := FetcherFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+
+	tests := []struct {
+		rate     float64
+		expected int
+	}{
+		{0, http.StatusOK},
+		{1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		fetcher := Chain(ok, InjectFaults(tt.rate, http.StatusServiceUnavailable))
+		for range 20 {
+			res, err := fetcher.Fetch(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.expected {
+				t.Fatalf("Rate %v: expected status %d, got %d", tt.rate, tt.expected, res.StatusCode)
+			}
+		}
+	}
+}
+
+// recordedFetcher answers requests from canned responses without a network
+type recordedFetcher struct {
+	mu        sync.Mutex
+	responses map[string]string
+	fetched   []string
+	// pages counts the requests made for frontier requests
+	pages int
+}
+
+func (f *recordedFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetched = append(f.fetched, req.URL.Path)
+	if RequestFromContext(req.Context()) != nil {
+		f.pages++
+	}
+	res := &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: http.NoBody, Request: req}
+	if body, ok := f.responses[req.URL.Path]; ok {
+		res.StatusCode = http.StatusOK
+		res.Header.Set("Content-Type", "text/html")
+		res.Body = io.NopCloser(strings.NewReader(body))
+	}
+	return res, nil
+}
+
+// TestCrawlerFetcher tests crawling through a custom fetcher wrapped in middleware
+func TestCrawlerFetcher(t *testing.T) {
+	fetcher := &recordedFetcher{responses: map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /private\n",
+		"/":           `<html><body><a href="/about">About</a><a href="/private">Private</a></body></html>`,
+		"/about":      `<html><body>About</body></html>`,
+	}}
+	var mu sync.Mutex
+	seen := 0
+	count := func(next Fetcher) Fetcher {
+		return FetcherFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			seen++
+			mu.Unlock()
+			return next.Fetch(req)
+		})
+	}
+
+	seedURL, _ := url.Parse("https://recorded.example/")
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*seedURL}, contentStorage, &Config{
+		WorkerCount:     1,
+		PolitenessDelay: time.Millisecond,
+		RevisitDelay:    time.Hour,
+		Fetcher:         fetcher,
+		Middleware:      []Middleware{count, LogFetches()},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	fetcher.mu.Lock()
+	defer fetcher.mu.Unlock()
+	if strings.Join(fetcher.fetched, ",") != "/robots.txt,/,/about" {
+		t.Errorf("Expected robots.txt, / and /about to be fetched, got %v", fetcher.fetched)
+	}
+	if fetcher.pages != 2 {
+		t.Errorf("Expected 2 page fetches to carry their frontier request, got %d", fetcher.pages)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if seen != len(fetcher.fetched) {
+		t.Errorf("Expected the middleware to see all %d requests, saw %d", len(fetcher.fetched), seen)
+	}
+}
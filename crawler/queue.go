@@ -1,34 +1,11 @@
 package crawler
 
 import (
-	"math/rand"
-	"net/url"
 	"sync"
 
-	"github.com/Fardin-E/web_crawler.git/frontier"
-
 	log "github.com/sirupsen/logrus"
 )
 
-func distributeUrls(frontier *frontier.Frontier, distributedInputs []chan *url.URL) {
-	HostToWorker := make(map[string]int)
-	for url := range frontier.Get() {
-		index := rand.Intn(len(distributedInputs))
-		if prevIndex, ok := HostToWorker[url.Host]; ok {
-			index = prevIndex
-		} else {
-			HostToWorker[url.Host] = index
-		}
-		distributedInputs[index] <- url
-	}
-
-	// Close all worker input channels when frontier is exhausted
-	log.Debug("Frontier exhausted, closing worker input channels")
-	for _, ch := range distributedInputs {
-		close(ch)
-	}
-}
-
 func mergeResults(workerResults []chan CrawlResult, out chan CrawlResult) {
 	var wg sync.WaitGroup
 
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+)
+
+// DefaultMaxRedirects is how many redirects are followed when
+// Config.MaxRedirects is zero, the limit of Go's default client
+const DefaultMaxRedirects = 10
+
+// Redirect is one hop of a redirect chain
+type Redirect struct {
+	// Url is the URL that answered with the redirect
+	Url        *url.URL
+	StatusCode int
+	// Location is the Location header as sent by the server
+	Location string
+}
+
+// RedirectError is returned when a redirect chain is not followed to the end
+type RedirectError struct {
+	Redirects []Redirect
+	// Target is the URL that was not followed
+	Target *url.URL
+	Reason string
+	// Refused is set when the frontier refused Target, e.g. because it is out
+	// of scope or was already seen. The redirect response is passed on as
+	// the result rather than dead-lettered.
+	Refused bool
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect from %s to %s not followed: %s", e.Redirects[0].Url, e.Target, e.Reason)
+}
+
+// RedirectFunc decides whether a redirect of req to target is followed,
+// returning the reason if not
+type RedirectFunc func(req *frontier.Request, target *url.URL) (bool, string)
+
+// isRedirect reports whether res redirects to another URL
+func isRedirect(res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return res.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// withoutRedirects returns a copy of client that returns redirect responses
+// instead of following them, so the worker can follow them itself
+func withoutRedirects(client *http.Client) *http.Client {
+	copied := *client
+	copied.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &copied
+}
+
+// follow fetches req, following redirects up to the worker's limit. It
+// returns the final response, the URL it was served from and the redirects
+// that led to it. When a redirect is refused it also returns the redirect
+// response, without its body, and the URL that answered with it.
+func (w *Worker) follow(req *frontier.Request) (*http.Response, *url.URL, []Redirect, error) {
+	current := req.Url
+	visited := map[string]bool{current.String(): true}
+	var redirects []Redirect
+	for {
+		httpReq, err := http.NewRequestWithContext(withRequest(context.Background(), req), http.MethodGet, current.String(), nil)
+		if err != nil {
+			return nil, current, redirects, err
+		}
+		if w.conditional != nil {
+			w.conditional.apply(httpReq, current)
+		}
+		res, err := w.fetcher.Fetch(httpReq)
+		if err != nil {
+			return nil, current, redirects, err
+		}
+		if !isRedirect(res) {
+			return res, current, redirects, nil
+		}
+		location := res.Header.Get("Location")
+		// Drain a little of the body so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+		res.Body.Close()
+
+		redirects = append(redirects, Redirect{Url: current, StatusCode: res.StatusCode, Location: location})
+		target, err := current.Parse(location)
+		if err != nil {
+			return nil, current, redirects, &RedirectError{Redirects: redirects, Target: current, Reason: fmt.Sprintf("invalid Location %q", location)}
+		}
+		target.Fragment = ""
+		switch {
+		case visited[target.String()]:
+			return nil, current, redirects, &RedirectError{Redirects: redirects, Target: target, Reason: "redirect loop"}
+		case len(redirects) > w.maxRedirects:
+			return nil, current, redirects, &RedirectError{Redirects: redirects, Target: target, Reason: fmt.Sprintf("more than %d redirects", w.maxRedirects)}
+		case target.Scheme != "http" && target.Scheme != "https":
+			return nil, current, redirects, &RedirectError{Redirects: redirects, Target: target, Reason: "unsupported scheme " + target.Scheme}
+		}
+		if w.checkRedirect != nil {
+			if ok, reason := w.checkRedirect(req, target); !ok {
+				res.Body = http.NoBody
+				return res, current, redirects, &RedirectError{Redirects: redirects, Target: target, Reason: reason, Refused: true}
+			}
+		}
+		w.logger.WithField("status", res.StatusCode).Debugf("Following redirect from %s to %s", current, target)
+		visited[target.String()] = true
+		current = target
+	}
+}
@@ -0,0 +1,234 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestWorkerRedirects tests that redirect chains are recorded, capped and checked for loops
+func TestWorkerRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c#top", http.StatusFound)
+		case "/c":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>C</body></html>"))
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		maxRedirects int
+		finalPath    string
+	}{
+		{"Chain", "/a", DefaultMaxRedirects, "/c"},
+		{"Loop", "/loop1", DefaultMaxRedirects, ""},
+		{"Too many", "/a", 1, ""},
+		{"None allowed", "/a", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := make(chan *frontier.Request, 1)
+			result := make(chan CrawlResult, 1)
+			done := make(chan struct{})
+			deadLetter := make(chan *frontier.Request, 1)
+			defer close(done)
+
+			worker := NewWorker(input, result, done, 0, deadLetter)
+			worker.maxRedirects = tt.maxRedirects
+			go worker.Start()
+
+			u, _ := url.Parse(server.URL + tt.path)
+			input <- frontier.NewRequest(u)
+
+			select {
+			case res := <-result:
+				if tt.finalPath == "" {
+					t.Fatalf("Expected the redirect not to be followed, got %s", res.FinalUrl)
+				}
+				if res.Url.Path != tt.path || res.FinalUrl.Path != tt.finalPath {
+					t.Errorf("Expected %s to end at %s, got %s", res.Url, tt.finalPath, res.FinalUrl)
+				}
+				if len(res.Redirects) != 2 || res.Redirects[0].StatusCode != http.StatusMovedPermanently ||
+					res.Redirects[1].Url.Path != "/b" || res.Redirects[1].Location != "/c#top" {
+					t.Errorf("Unexpected redirect chain: %+v", res.Redirects)
+				}
+			case <-deadLetter:
+				if tt.finalPath != "" {
+					t.Error("Expected the redirect to be followed")
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Timeout")
+			}
+		})
+	}
+}
+
+// TestCrawlerRedirects tests that redirect targets are checked against the scope and fetched only once
+func TestCrawlerRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Out of scope redirect to %s was followed", r.URL)
+	}))
+	defer other.Close()
+	// A different hostname for the same address is another host to the scope
+	otherURL, _ := url.Parse(other.URL)
+	otherURL.Host = "localhost:" + otherURL.Port()
+
+	var mu sync.Mutex
+	fetches := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/old">Old</a><a href="/new">New</a><a href="/away">Away</a><a href="/loop1">Loop</a></body></html>`))
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/away":
+			http.Redirect(w, r, otherURL.String()+"/", http.StatusFound)
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>New</body></html>`))
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	store := &deadLetters{}
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     1,
+		PolitenessDelay: time.Millisecond,
+		RevisitDelay:    time.Hour,
+		Scope:           frontier.ScopeSameHost,
+		DeadLetters:     store,
+	})
+	var redirectsMu sync.Mutex
+	refused := map[string]*CrawlResult{}
+	crawler.AddProcessor(&TestProcessor{callback: func(result *CrawlResult) error {
+		if result.StatusCode >= 300 && result.StatusCode < 400 {
+			redirectsMu.Lock()
+			refused[result.Url.Path] = result
+			redirectsMu.Unlock()
+		}
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	mu.Lock()
+	if fetches["/new"] != 1 {
+		t.Errorf("Expected /new to be fetched once, got %d", fetches["/new"])
+	}
+	mu.Unlock()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.letters) != 1 || store.letters[0].Class != ErrorRedirect {
+		t.Fatalf("Expected only the redirect loop to be dead-lettered, got %+v", store.letters)
+	}
+	if u, _ := url.Parse(store.letters[0].Url); u.Path != "/loop1" {
+		t.Errorf("Expected /loop1 to be dead-lettered, got %s", store.letters[0].Url)
+	}
+	if rejected := crawler.Rejected(); rejected["outside same-host scope"] != 1 {
+		t.Errorf("Expected the out of scope redirect to be rejected, got %v", rejected)
+	}
+
+	// Refused redirects are passed on as the redirect response
+	redirectsMu.Lock()
+	defer redirectsMu.Unlock()
+	if len(refused) != 2 {
+		t.Errorf("Expected the refused redirects of /old and /away as results, got %v", refused)
+	}
+	if result, ok := refused["/old"]; ok {
+		if result.StatusCode != http.StatusMovedPermanently || result.FinalUrl.Path != "/old" ||
+			len(result.Redirects) != 1 || result.Redirects[0].Location != "/new" {
+			t.Errorf("Unexpected result for the refused redirect of /old: %+v", result)
+		}
+	}
+}
+
+// TestCrawlerRevisitsRedirect tests that revisits of a redirecting page follow it while its target is still seen
+func TestCrawlerRevisitsRedirect(t *testing.T) {
+	var mu sync.Mutex
+	fetches := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/docs":
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Docs</body></html>`))
+		}
+	}))
+	defer server.Close()
+	seedURL, _ := url.Parse(server.URL + "/docs")
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*seedURL}, contentStorage, &Config{
+		WorkerCount:     1,
+		PolitenessDelay: time.Millisecond,
+		// The target stays seen for the whole crawl
+		RevisitDelay: time.Hour,
+		Watch:        true,
+		Recrawl:      frontier.RecrawlPolicy{MinInterval: 50 * time.Millisecond, MaxInterval: 50 * time.Millisecond},
+		MaxDuration:  500 * time.Millisecond,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches["/docs"] < 3 || fetches["/docs/"] != fetches["/docs"] {
+		t.Errorf("Expected every revisit of /docs to reach /docs/, got %v", fetches)
+	}
+}
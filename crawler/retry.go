@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorClass groups fetch errors for the retry policy
+type ErrorClass string
+
+const (
+	// ErrorTimeout is a request that timed out
+	ErrorTimeout ErrorClass = "timeout"
+	// ErrorConnection is a refused, reset or prematurely closed connection
+	ErrorConnection ErrorClass = "connection"
+	// ErrorDNS is a failed host name lookup
+	ErrorDNS ErrorClass = "dns"
+	// ErrorStatus is a response with an unexpected status code
+	ErrorStatus ErrorClass = "status"
+	// ErrorRedirect is a redirect loop or chain that is too long
+	ErrorRedirect ErrorClass = "redirect"
+	ErrorOther    ErrorClass = "other"
+)
+
+// ParseErrorClass validates the name of an error class
+func ParseErrorClass(name string) (ErrorClass, error) {
+	switch class := ErrorClass(strings.ToLower(name)); class {
+	case ErrorTimeout, ErrorConnection, ErrorDNS, ErrorStatus, ErrorRedirect, ErrorOther:
+		return class, nil
+	}
+	return "", fmt.Errorf("unknown error class %q, expected one of %s, %s, %s, %s, %s, %s",
+		name, ErrorTimeout, ErrorConnection, ErrorDNS, ErrorStatus, ErrorRedirect, ErrorOther)
+}
+
+// StatusError describes a response with a retryable status code, in retry
+// logs and dead letters
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %d %s", e.StatusCode, e.Status)
+}
+
+// classifyError returns the class of a fetch error
+func classifyError(err error) ErrorClass {
+	var statusErr *StatusError
+	var redirectErr *RedirectError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorStatus
+	case errors.As(err, &redirectErr):
+		return ErrorRedirect
+	case errors.As(err, &dnsErr) && !dnsErr.IsTimeout:
+		return ErrorDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return ErrorConnection
+	}
+	return ErrorOther
+}
+
+var (
+	// DefaultRetryStatuses are the status codes retried when none are configured
+	DefaultRetryStatuses = []int{
+		http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	// DefaultRetryClasses are the error classes retried when none are configured
+	DefaultRetryClasses = []ErrorClass{ErrorTimeout, ErrorConnection}
+)
+
+const (
+	// DefaultRetryBaseDelay and DefaultRetryMaxDelay bound the backoff when
+	// RetryPolicy leaves them zero
+	DefaultRetryBaseDelay = time.Second
+	DefaultRetryMaxDelay  = time.Minute
+)
+
+// RetryPolicy decides which failed fetches are tried again and when. The
+// n-th retry waits a random time between half and all of BaseDelay*2^(n-1),
+// capped at MaxDelay, or the response's Retry-After if that is longer.
+type RetryPolicy struct {
+	// MaxAttempts is the most fetches of a URL, retries are disabled if it
+	// is 1 or less
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Statuses are retried status codes, DefaultRetryStatuses if nil
+	Statuses []int
+	// Classes are retried error classes besides ErrorStatus,
+	// DefaultRetryClasses if nil
+	Classes []ErrorClass
+}
+
+// retryable reports whether a fetch that failed with err and status may be retried
+func (p RetryPolicy) retryable(class ErrorClass, status int) bool {
+	if class == ErrorStatus {
+		statuses := p.Statuses
+		if statuses == nil {
+			statuses = DefaultRetryStatuses
+		}
+		return slices.Contains(statuses, status)
+	}
+	classes := p.Classes
+	if classes == nil {
+		classes = DefaultRetryClasses
+	}
+	return slices.Contains(classes, class)
+}
+
+// backoff returns the wait before retrying after attempts failed fetches
+func (p RetryPolicy) backoff(attempts int, retryAfter time.Duration) time.Duration {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	// Full jitter over the upper half keeps retries of many URLs from
+	// arriving in bursts
+	delay = delay/2 + rand.N(delay/2+1)
+	return max(delay, retryAfter)
+}
+
+// retrier retries failed fetches and records the ones that fail for good
+type retrier struct {
+	policy RetryPolicy
+	// requeue hands a request back to the scheduler
+	requeue func(req *frontier.Request)
+	// store is nil when dead letters are only logged
+	store      DeadLetterStore
+	deadLetter chan *frontier.Request
+
+	mu       sync.Mutex
+	attempts map[*frontier.Request]int
+	timers   map[*time.Timer]struct{}
+	stopped  bool
+}
+
+func newRetrier(policy RetryPolicy, store DeadLetterStore, deadLetter chan *frontier.Request, requeue func(*frontier.Request)) *retrier {
+	return &retrier{
+		policy:     policy,
+		requeue:    requeue,
+		store:      store,
+		deadLetter: deadLetter,
+		attempts:   make(map[*frontier.Request]int),
+		timers:     make(map[*time.Timer]struct{}),
+	}
+}
+
+// failed schedules a retry of req, or dead-letters it when it may not be
+// retried. It is called from the worker that fetched req.
+func (r *retrier) failed(req *frontier.Request, err error, feedback fetchFeedback) {
+	class := classifyError(err)
+	retried, attempts := r.attempt(req, class, err, feedback)
+	if retried {
+		return
+	}
+	r.record(req, err, class, feedback.status, attempts)
+	r.deadLetter <- req
+}
+
+// completed is called with the response fetched for req. It schedules a
+// retry and returns true when the status is retryable and attempts remain.
+// A retryable status that is still returned after the last attempt is
+// recorded as a dead letter, and the response is passed on all the same.
+func (r *retrier) completed(req *frontier.Request, result *CrawlResult, feedback fetchFeedback) bool {
+	if result.Successful() {
+		r.succeeded(req)
+		return false
+	}
+	err := &StatusError{StatusCode: result.StatusCode, Status: result.Status}
+	retried, attempts := r.attempt(req, ErrorStatus, err, feedback)
+	if !retried && r.policy.retryable(ErrorStatus, result.StatusCode) {
+		r.record(req, err, ErrorStatus, result.StatusCode, attempts)
+	}
+	return retried
+}
+
+// attempt counts a failed attempt at req and schedules a retry if the policy
+// allows one, returning whether it did and how many attempts were made
+func (r *retrier) attempt(req *frontier.Request, class ErrorClass, err error, feedback fetchFeedback) (bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[req]++
+	attempts := r.attempts[req]
+	if r.stopped || attempts >= r.policy.MaxAttempts || !r.policy.retryable(class, feedback.status) {
+		delete(r.attempts, req)
+		return false, attempts
+	}
+
+	delay := r.policy.backoff(attempts, feedback.retryAfter)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		delete(r.timers, timer)
+		r.mu.Unlock()
+		r.requeue(req)
+	})
+	r.timers[timer] = struct{}{}
+	log.WithFields(log.Fields{
+		"url":     req.Url,
+		"attempt": attempts,
+		"delay":   delay,
+	}).Infof("Retrying after %s", err)
+	return true, attempts
+}
+
+// record stores a dead letter for req, if there is a store
+func (r *retrier) record(req *frontier.Request, err error, class ErrorClass, status int, attempts int) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.Record(newDeadLetter(req, err, class, status, attempts)); err != nil {
+		log.WithField("url", req.Url).Errorf("Failed to record dead letter: %s", err)
+	}
+}
+
+// succeeded forgets the failed attempts of req
+func (r *retrier) succeeded(req *frontier.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, req)
+}
+
+// stop cancels the pending retries, their requests are left unfinished so a
+// persistent frontier fetches them when the crawl is resumed
+func (r *retrier) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	for timer := range r.timers {
+		timer.Stop()
+	}
+	clear(r.timers)
+}
@@ -0,0 +1,222 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestClassifyError tests sorting fetch errors into retry classes
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{&StatusError{StatusCode: 503, Status: "503 Service Unavailable"}, ErrorStatus},
+		{fmt.Errorf("get: %w", &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}), ErrorDNS},
+		{&net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, ErrorTimeout},
+		{&url.Error{Op: "Get", URL: "http://example.com", Err: context.DeadlineExceeded}, ErrorTimeout},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorConnection},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), ErrorConnection},
+		{errors.New("unsupported protocol scheme"), ErrorOther},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.expected {
+			t.Errorf("classifyError(%v): expected %s, got %s", tt.err, tt.expected, got)
+		}
+	}
+}
+
+// TestRetryPolicyRetryable tests which statuses and error classes are retried
+func TestRetryPolicyRetryable(t *testing.T) {
+	defaults := RetryPolicy{MaxAttempts: 3}
+	custom := RetryPolicy{MaxAttempts: 3, Statuses: []int{404}, Classes: []ErrorClass{ErrorDNS}}
+	tests := []struct {
+		policy   RetryPolicy
+		class    ErrorClass
+		status   int
+		expected bool
+	}{
+		{defaults, ErrorStatus, 503, true},
+		{defaults, ErrorStatus, 429, true},
+		{defaults, ErrorStatus, 404, false},
+		{defaults, ErrorTimeout, 0, true},
+		{defaults, ErrorConnection, 0, true},
+		{defaults, ErrorDNS, 0, false},
+		{custom, ErrorStatus, 404, true},
+		{custom, ErrorStatus, 503, false},
+		{custom, ErrorDNS, 0, true},
+		{custom, ErrorTimeout, 0, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.retryable(tt.class, tt.status); got != tt.expected {
+			t.Errorf("retryable(%s, %d) with %+v: expected %v, got %v", tt.class, tt.status, tt.policy, tt.expected, got)
+		}
+	}
+}
+
+// TestRetryPolicyBackoff tests that the backoff doubles with jitter, is capped and honours Retry-After
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempts   int
+		retryAfter time.Duration
+		low, high  time.Duration
+	}{
+		{1, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 0, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 0, 500 * time.Millisecond, time.Second},
+		{1, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 50 {
+			got := policy.backoff(tt.attempts, tt.retryAfter)
+			if got < tt.low || got > tt.high {
+				t.Fatalf("backoff(%d, %s): expected between %s and %s, got %s", tt.attempts, tt.retryAfter, tt.low, tt.high, got)
+			}
+		}
+	}
+}
+
+// TestDeadLetterFile tests that dead letters are appended and read back as requests
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	if letters, err := ReadDeadLetters(path); err != nil || len(letters) != 0 {
+		t.Fatalf("Expected a missing file to hold no dead letters, got %v, %v", letters, err)
+	}
+
+	seed, _ := url.Parse("https://example.com/")
+	page, _ := url.Parse("https://example.com/broken")
+	req := frontier.NewRequest(seed).Child(page)
+	req.MaxDepth = 4
+	req.Scope = frontier.ScopeSameHost
+
+	file, err := OpenDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.Record(newDeadLetter(req, &StatusError{StatusCode: 500, Status: "500 Internal Server Error"}, ErrorStatus, 500, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	letters, err := ReadDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+	letter := letters[0]
+	if letter.Url != page.String() || letter.Status != 500 || letter.Attempts != 3 || letter.Class != ErrorStatus {
+		t.Errorf("Unexpected dead letter: %+v", letter)
+	}
+
+	replayed, err := letter.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Url.String() != page.String() || replayed.Depth != 1 || replayed.Parent.String() != seed.String() ||
+		replayed.Seed.String() != seed.String() || replayed.MaxDepth != 4 || replayed.Scope != frontier.ScopeSameHost {
+		t.Errorf("Unexpected replayed request: %+v", replayed)
+	}
+}
+
+// deadLetters collects dead letters in memory
+type deadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func (d *deadLetters) Record(letter DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters = append(d.letters, letter)
+	return nil
+}
+
+// TestCrawlerRetries tests that retryable failures are fetched again and the rest are dead-lettered
+func TestCrawlerRetries(t *testing.T) {
+	var mu sync.Mutex
+	fetches := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		count := fetches[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt", "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/flaky">Flaky</a><a href="/broken">Broken</a><a href="/missing">Missing</a></body></html>`))
+		case "/flaky":
+			if count < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Back</body></html>`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	store := &deadLetters{}
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     2,
+		PolitenessDelay: time.Millisecond,
+		RevisitDelay:    time.Hour,
+		Retry:           RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond},
+		DeadLetters:     store,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := map[string]int{"/flaky": 3, "/broken": 3, "/missing": 1}
+	for path, count := range expected {
+		if fetches[path] != count {
+			t.Errorf("Expected %s to be fetched %d times, got %d", path, count, fetches[path])
+		}
+	}
+
+	// A 404 is a result, only the status that kept failing is dead-lettered
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %+v", store.letters)
+	}
+	if u, _ := url.Parse(store.letters[0].Url); u.Path != "/broken" || store.letters[0].Attempts != 3 {
+		t.Errorf("Unexpected dead letter: %+v", store.letters[0])
+	}
+}
@@ -0,0 +1,25 @@
+package crawler
+
+import (
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/robots"
+)
+
+// robotsFilter refuses requests for paths robots.txt disallows, and defers
+// requests to hosts whose robots.txt cannot be fetched until it is retried
+type robotsFilter struct {
+	cache *robots.Cache
+}
+
+func (r *robotsFilter) Defer(req *frontier.Request) time.Duration {
+	return r.cache.RetryIn(req.Url)
+}
+
+func (r *robotsFilter) Allow(req *frontier.Request) (bool, string) {
+	if !r.cache.Allowed(req.Url) {
+		return false, "disallowed by robots.txt"
+	}
+	return true, ""
+}
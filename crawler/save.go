@@ -7,15 +7,38 @@ import (
 	"path"
 	"strings"
 
+	"github.com/Fardin-E/web_crawler.git/parser"
 	"github.com/Fardin-E/web_crawler.git/storage"
+	log "github.com/sirupsen/logrus"
 )
 
 type SaveToFile struct {
 	storageBackend storage.Storage
+	// validators, if set, records the validators of the pages stored, so only
+	// pages with a stored copy are fetched conditionally
+	validators *conditional
 }
 
 func (s *SaveToFile) Process(result *CrawlResult) error {
-	savePath := getSavePath(result.Url)
+	// Redirected pages are stored under the URL that served them
+	u := result.Url
+	if result.FinalUrl != nil {
+		u = result.FinalUrl
+	}
+	// The stored copy of an unchanged page stays, its validators may change.
+	// Info was read from the stored copy, without one the page must be
+	// downloaded again.
+	if result.NotModified {
+		if result.Info == nil {
+			return nil
+		}
+		return s.recordValidators(u, result)
+	}
+	// Error pages must not replace a stored copy of the page
+	if !result.Successful() {
+		return nil
+	}
+	savePath := getSavePath(u)
 
 	switch {
 	case strings.HasPrefix(result.ContentType, "text/html"):
@@ -25,7 +48,10 @@ func (s *SaveToFile) Process(result *CrawlResult) error {
 			if err != nil {
 				return err
 			}
-			return s.storageBackend.Set(jsonPath, string(data))
+			if err := s.storageBackend.Set(jsonPath, string(data)); err != nil {
+				return err
+			}
+			return s.recordValidators(u, result)
 		}
 
 		return nil
@@ -36,8 +62,43 @@ func (s *SaveToFile) Process(result *CrawlResult) error {
 	}
 }
 
-func getSavePath(url *url.URL) string {
-	fileName := url.Path
-	savePath := path.Join(url.Host, fileName)
+func (s *SaveToFile) recordValidators(u *url.URL, result *CrawlResult) error {
+	if s.validators == nil {
+		return nil
+	}
+	if err := s.validators.update(u, result.StatusCode, result.Header); err != nil {
+		return fmt.Errorf("storing validators of %s: %w", u, err)
+	}
+	return nil
+}
+
+// storedInfo returns the parsed page SaveToFile stored for result, nil if
+// there is none
+func storedInfo(storageBackend storage.Storage, result *CrawlResult) *parser.Info {
+	u := result.Url
+	if result.FinalUrl != nil {
+		u = result.FinalUrl
+	}
+	data, err := storageBackend.Get(getSavePath(u) + ".json")
+	if err != nil {
+		log.WithField("url", u).Debugf("No stored copy of unchanged page: %s", err)
+		return nil
+	}
+	var info parser.Info
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		log.WithField("url", u).Warnf("Ignoring invalid stored copy: %s", err)
+		return nil
+	}
+	return &info
+}
+
+// getSavePath returns where the page at u is stored. The query is kept,
+// escaped, so the pages of a paginated list do not overwrite each other.
+func getSavePath(u *url.URL) string {
+	fileName := u.Path
+	savePath := path.Join(u.Host, fileName)
+	if u.RawQuery != "" {
+		savePath += url.PathEscape("?" + u.RawQuery)
+	}
 	return savePath
 }
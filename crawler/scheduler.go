@@ -0,0 +1,348 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	log "github.com/sirupsen/logrus"
+)
+
+// sweepEvery is how many dispatches pass between sweeps of idle hosts
+const sweepEvery = 1000
+
+// schedulerBuffer is how many requests per worker the scheduler takes from
+// the frontier ahead of dispatching them
+const schedulerBuffer = 4
+
+// HostPolicy overrides the politeness settings for hosts matching Host
+type HostPolicy struct {
+	// Host is a glob matched against the URL hostname, e.g. "*.example.com"
+	Host string
+	// Delay is the minimum time between the starts of two fetches to the
+	// host, Config.PolitenessDelay if zero
+	Delay time.Duration
+	// MaxConnections is the most concurrent fetches to the host,
+	// Config.MaxConnectionsPerHost if zero
+	MaxConnections int
+	// MaxCrawlDelay caps the robots.txt Crawl-delay of the host,
+	// Config.MaxCrawlDelay if zero. A negative value ignores Crawl-delay.
+	MaxCrawlDelay time.Duration
+}
+
+func (p HostPolicy) matches(u *url.URL) bool {
+	ok, _ := path.Match(p.Host, strings.ToLower(u.Hostname()))
+	return ok
+}
+
+// ParseHostPolicy parses "host:key=value,...", where host is a hostname glob
+// and the keys are delay, connections and max-crawl-delay, for example
+// "*.example.com:delay=500ms,connections=4"
+func ParseHostPolicy(spec string) (HostPolicy, error) {
+	host, settings, ok := strings.Cut(spec, ":")
+	if !ok || host == "" || settings == "" {
+		return HostPolicy{}, fmt.Errorf("host policy %q: expected host:key=value,...", spec)
+	}
+	if _, err := path.Match(host, ""); err != nil {
+		return HostPolicy{}, fmt.Errorf("host policy %q: %w", spec, err)
+	}
+
+	policy := HostPolicy{Host: strings.ToLower(host)}
+	for _, setting := range strings.Split(settings, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		var err error
+		switch key {
+		case "delay":
+			policy.Delay, err = time.ParseDuration(value)
+		case "connections":
+			policy.MaxConnections, err = strconv.Atoi(value)
+			if err == nil && policy.MaxConnections < 1 {
+				err = fmt.Errorf("connections must be at least 1")
+			}
+		case "max-crawl-delay":
+			policy.MaxCrawlDelay, err = time.ParseDuration(value)
+		default:
+			err = fmt.Errorf("unknown setting %q, expected delay, connections or max-crawl-delay", key)
+		}
+		if err != nil {
+			return HostPolicy{}, fmt.Errorf("host policy %q: %w", spec, err)
+		}
+	}
+	return policy, nil
+}
+
+// hostQueue holds the requests waiting for one host
+type hostQueue struct {
+	host string
+	reqs []*frontier.Request
+	// active counts fetches handed to workers and not released yet
+	active         int
+	maxConnections int
+	// nextFetch is the earliest time the next fetch may start
+	nextFetch time.Time
+	// delay is the current time between fetches, baseDelay the politeness
+	// delay it started from. They only differ when throttling.
+	delay     time.Duration
+	baseDelay time.Duration
+	// latency is the average response time
+	latency time.Duration
+	// throttled counts responses asking the crawler to slow down
+	throttled int
+	// index is the position in the ready heap, -1 when not in it
+	index int
+}
+
+// readyHeap implements heap.Interface over hosts that have requests and a
+// free connection, the host allowed to fetch first on top
+type readyHeap []*hostQueue
+
+func (h readyHeap) Len() int { return len(h) }
+
+func (h readyHeap) Less(i, j int) bool {
+	return h[i].nextFetch.Before(h[j].nextFetch)
+}
+
+func (h readyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *readyHeap) Push(x any) {
+	q := x.(*hostQueue)
+	q.index = len(*h)
+	*h = append(*h, q)
+}
+
+func (h *readyHeap) Pop() any {
+	old := *h
+	n := len(old)
+	q := old[n-1]
+	old[n-1] = nil
+	q.index = -1
+	*h = old[:n-1]
+	return q
+}
+
+// hostScheduler keeps a queue per host and hands the next request of a host
+// that may be fetched to whichever worker is idle, so a slow or delayed host
+// never holds up the others
+type hostScheduler struct {
+	mu    sync.Mutex
+	hosts map[string]*hostQueue
+	ready readyHeap
+	// wake interrupts the wait for the next ready host
+	wake chan struct{}
+	// delay and connections return the politeness settings of a host
+	delay       func(u *url.URL) time.Duration
+	connections func(u *url.URL) int
+	// throttle is nil unless delays adapt to how hosts respond
+	throttle *throttle
+	// limit is how many requests are taken from the frontier ahead of the
+	// workers, unbounded if zero. Requests left in the frontier keep their
+	// priority order and, with a DiskQueue, stay on disk.
+	limit int
+	// queued counts the requests waiting in all host queues
+	queued     int
+	dispatched int
+}
+
+func newHostScheduler(delay func(*url.URL) time.Duration, connections func(*url.URL) int) *hostScheduler {
+	return &hostScheduler{
+		hosts:       make(map[string]*hostQueue),
+		wake:        make(chan struct{}, 1),
+		delay:       delay,
+		connections: connections,
+	}
+}
+
+// run moves requests from in to out as their hosts allow, closing out once
+// in is closed. Requests still queued then are dropped.
+func (s *hostScheduler) run(in <-chan *frontier.Request, out chan<- *frontier.Request) {
+	defer close(out)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		next, wait := s.peek(time.Now())
+		full := s.limit > 0 && s.queued >= s.limit
+		s.mu.Unlock()
+
+		// Leave requests in the frontier while enough are buffered
+		receive := in
+		if full {
+			receive = nil
+		}
+
+		// Only offer a request to the workers when one is ready
+		var send chan<- *frontier.Request
+		if next != nil {
+			send = out
+		} else {
+			timer.Reset(wait)
+		}
+
+		select {
+		case req, ok := <-receive:
+			if !ok {
+				log.Debug("Frontier exhausted, closing worker input channel")
+				return
+			}
+			s.add(req)
+		case send <- next:
+			s.take(next)
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// add queues req for its host. It may be called from outside run, e.g. to
+// retry a failed request.
+func (s *hostScheduler) add(req *frontier.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}()
+	key := strings.ToLower(req.Url.Host)
+	q, ok := s.hosts[key]
+	if !ok {
+		q = &hostQueue{host: key, maxConnections: max(s.connections(req.Url), 1), index: -1}
+		s.hosts[key] = q
+	}
+	q.reqs = append(q.reqs, req)
+	s.queued++
+	s.update(q)
+}
+
+// peek returns the next request that may be fetched, or how long until one
+// may be if none can yet, s.mu must be held
+func (s *hostScheduler) peek(now time.Time) (*frontier.Request, time.Duration) {
+	if len(s.ready) == 0 {
+		return nil, time.Hour
+	}
+	q := s.ready[0]
+	if wait := q.nextFetch.Sub(now); wait > 0 {
+		return nil, wait
+	}
+	return q.reqs[0], 0
+}
+
+// take records that the request returned by peek was handed to a worker
+func (s *hostScheduler) take(req *frontier.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.hosts[strings.ToLower(req.Url.Host)]
+	q.reqs[0] = nil
+	q.reqs = q.reqs[1:]
+	s.queued--
+	q.active++
+	// A throttled host keeps its adapted delay
+	if s.throttle == nil || q.baseDelay == 0 {
+		q.baseDelay = s.delay(req.Url)
+		q.delay = q.baseDelay
+	}
+	q.nextFetch = time.Now().Add(q.delay)
+	s.update(q)
+
+	s.dispatched++
+	if s.dispatched%sweepEvery == 0 {
+		s.sweep()
+	}
+}
+
+// release records that a worker finished with req and how its host responded
+func (s *hostScheduler) release(req *frontier.Request, feedback fetchFeedback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.hosts[strings.ToLower(req.Url.Host)]
+	if !ok || q.active == 0 {
+		return
+	}
+	q.active--
+	if s.throttle != nil && feedback.fetched {
+		s.throttle.adapt(q, req.Url, feedback, time.Now())
+	}
+	s.update(q)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// update puts q in the ready heap if it has requests and a free connection
+// and takes it out otherwise, s.mu must be held
+func (s *hostScheduler) update(q *hostQueue) {
+	ready := len(q.reqs) > 0 && q.active < q.maxConnections
+	switch {
+	case ready && q.index < 0:
+		heap.Push(&s.ready, q)
+	case ready:
+		heap.Fix(&s.ready, q.index)
+	case q.index >= 0:
+		heap.Remove(&s.ready, q.index)
+	}
+}
+
+// sweep forgets idle hosts whose politeness delay has passed, unless they
+// are throttled, s.mu must be held
+func (s *hostScheduler) sweep() {
+	now := time.Now()
+	for key, q := range s.hosts {
+		if len(q.reqs) == 0 && q.active == 0 && now.After(q.nextFetch) && q.delay <= q.baseDelay {
+			delete(s.hosts, key)
+		}
+	}
+}
+
+// HostStats describes how the crawl treats a host
+type HostStats struct {
+	Host string `json:"host"`
+	// Queued requests wait for the host's next fetch slot
+	Queued int `json:"queued"`
+	Active int `json:"active"`
+	// Delay is the current time between fetches, BaseDelay the politeness
+	// delay. Delay is longer while the host is pushing back.
+	Delay     time.Duration `json:"delay"`
+	BaseDelay time.Duration `json:"base_delay"`
+	// Latency is the average response time, measured when throttling
+	Latency time.Duration `json:"latency"`
+	// Throttled counts 429 and 503 responses
+	Throttled int `json:"throttled"`
+}
+
+// stats returns the hosts the scheduler knows, slowest first
+func (s *hostScheduler) stats() []HostStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]HostStats, 0, len(s.hosts))
+	for _, q := range s.hosts {
+		stats = append(stats, HostStats{
+			Host:      q.host,
+			Queued:    len(q.reqs),
+			Active:    q.active,
+			Delay:     q.delay,
+			BaseDelay: q.baseDelay,
+			Latency:   q.latency,
+			Throttled: q.throttled,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Delay != stats[j].Delay {
+			return stats[i].Delay > stats[j].Delay
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+)
+
+func newSchedulerRequest(raw string) *frontier.Request {
+	u, _ := url.Parse(raw)
+	return frontier.NewRequest(u)
+}
+
+// TestHostSchedulerSlowHost tests that a host with a long delay does not hold up other hosts
+func TestHostSchedulerSlowHost(t *testing.T) {
+	scheduler := newHostScheduler(func(u *url.URL) time.Duration {
+		if u.Host == "slow.com" {
+			return 200 * time.Millisecond
+		}
+		return 10 * time.Millisecond
+	}, func(*url.URL) int { return 1 })
+	in := make(chan *frontier.Request, 6)
+	out := make(chan *frontier.Request)
+	go scheduler.run(in, out)
+
+	for i := range 3 {
+		in <- newSchedulerRequest("https://slow.com/" + string(rune('a'+i)))
+		in <- newSchedulerRequest("https://fast.com/" + string(rune('a'+i)))
+	}
+
+	start := time.Now()
+	fastDone := time.Duration(0)
+	fetched := map[string]int{}
+	for range 6 {
+		req := <-out
+		fetched[req.Url.Host]++
+		scheduler.release(req, fetchFeedback{})
+		if req.Url.Host == "fast.com" && fetched["fast.com"] == 3 {
+			fastDone = time.Since(start)
+		}
+	}
+	elapsed := time.Since(start)
+	close(in)
+
+	if fastDone > 150*time.Millisecond {
+		t.Errorf("Fast host was held up by the slow one, done after %s", fastDone)
+	}
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("Slow host delay not respected, all fetched after %s", elapsed)
+	}
+	if _, ok := <-out; ok {
+		t.Error("Output should be closed once the input is")
+	}
+}
+
+// TestHostSchedulerMaxConnections tests that a host never has more fetches in flight than allowed
+func TestHostSchedulerMaxConnections(t *testing.T) {
+	scheduler := newHostScheduler(func(*url.URL) time.Duration { return 0 }, func(*url.URL) int { return 2 })
+	in := make(chan *frontier.Request, 3)
+	out := make(chan *frontier.Request)
+	go scheduler.run(in, out)
+	defer close(in)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		in <- newSchedulerRequest("https://example.com" + path)
+	}
+	first := <-out
+	<-out
+	select {
+	case req := <-out:
+		t.Fatalf("Got %s while two fetches were in flight", req)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	scheduler.release(first, fetchFeedback{})
+	select {
+	case req := <-out:
+		if req.Url.Path != "/c" {
+			t.Errorf("Expected /c, got %s", req)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Released connection was not reused")
+	}
+}
+
+// TestHostSchedulerLimit tests that requests beyond the limit are left in the input
+func TestHostSchedulerLimit(t *testing.T) {
+	scheduler := newHostScheduler(func(*url.URL) time.Duration { return time.Hour }, func(*url.URL) int { return 1 })
+	scheduler.limit = 2
+	in := make(chan *frontier.Request, 5)
+	out := make(chan *frontier.Request)
+	go scheduler.run(in, out)
+	defer close(in)
+
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		in <- newSchedulerRequest("https://example.com" + path)
+	}
+	first := <-out
+	if first.Url.Path != "/a" {
+		t.Errorf("Expected /a first, got %s", first)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(in) != 2 {
+		t.Errorf("Expected 2 requests left in the input, got %d", len(in))
+	}
+	if stats := scheduler.stats(); len(stats) != 1 || stats[0].Queued != 2 {
+		t.Errorf("Expected 2 requests queued for the host, got %+v", stats)
+	}
+}
+
+// TestParseHostPolicy tests parsing per-host politeness settings
+func TestParseHostPolicy(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected HostPolicy
+		wantErr  bool
+	}{
+		{"example.com:delay=500ms", HostPolicy{Host: "example.com", Delay: 500 * time.Millisecond}, false},
+		{"*.Example.com:connections=4,max-crawl-delay=10s", HostPolicy{Host: "*.example.com", MaxConnections: 4, MaxCrawlDelay: 10 * time.Second}, false},
+		{"slow.org:max-crawl-delay=-1s", HostPolicy{Host: "slow.org", MaxCrawlDelay: -time.Second}, false},
+		{"example.com", HostPolicy{}, true},
+		{"example.com:connections=0", HostPolicy{}, true},
+		{"example.com:speed=fast", HostPolicy{}, true},
+		{"[:delay=1s", HostPolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			policy, err := ParseHostPolicy(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if policy != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, policy)
+			}
+		})
+	}
+}
+
+// TestCrawlerHostPolicies tests which politeness settings apply to a host
+func TestCrawlerHostPolicies(t *testing.T) {
+	crawler := &Crawler{config: &Config{
+		PolitenessDelay:       time.Second,
+		MaxConnectionsPerHost: 2,
+		HostPolicies: []HostPolicy{
+			{Host: "*.example.com", Delay: 100 * time.Millisecond, MaxConnections: 8},
+			{Host: "slow.org", Delay: 5 * time.Second},
+		},
+	}}
+
+	tests := []struct {
+		rawURL      string
+		delay       time.Duration
+		connections int
+	}{
+		{"https://docs.example.com/", 100 * time.Millisecond, 8},
+		{"https://SLOW.org:8443/", 5 * time.Second, 2},
+		{"https://other.net/", time.Second, 2},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		if delay := crawler.politenessDelay(u); delay != tt.delay {
+			t.Errorf("%s: expected delay %s, got %s", tt.rawURL, tt.delay, delay)
+		}
+		if connections := crawler.maxConnections(u); connections != tt.connections {
+			t.Errorf("%s: expected %d connections, got %d", tt.rawURL, tt.connections, connections)
+		}
+	}
+}
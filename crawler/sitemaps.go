@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"net/url"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+	"github.com/Fardin-E/web_crawler.git/robots"
+	"github.com/Fardin-E/web_crawler.git/sitemap"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sitemapUrls returns the configured sitemaps plus, when discovery is
+// enabled, the sitemaps advertised by the robots.txt of every seed host
+func (c *Crawler) sitemapUrls() []string {
+	sitemapUrls := append([]string{}, c.config.Sitemaps...)
+	if !c.config.DiscoverSitemaps {
+		return sitemapUrls
+	}
+
+	robotsCache := c.robots
+	if robotsCache == nil {
+		// Sitemap lines are still worth reading when robots rules are ignored
+		robotsCache = robots.NewCache(c.client, c.config.userAgent())
+	}
+	seenHosts := map[string]bool{}
+	for _, seed := range c.seeds {
+		origin := seed.Scheme + "://" + seed.Host
+		if seenHosts[origin] {
+			continue
+		}
+		seenHosts[origin] = true
+
+		advertised := robotsCache.Sitemaps(&seed)
+		if len(advertised) == 0 {
+			advertised = []string{origin + "/sitemap.xml"}
+		}
+		sitemapUrls = append(sitemapUrls, advertised...)
+	}
+	return sitemapUrls
+}
+
+// seedFromSitemaps adds every entry of the configured and discovered
+// sitemaps to the frontier as a seed
+func (c *Crawler) seedFromSitemaps() {
+	for _, sitemapUrl := range c.sitemapUrls() {
+		logger := log.WithField("sitemap", sitemapUrl)
+		parent, err := url.Parse(sitemapUrl)
+		if err != nil {
+			logger.Warnf("Invalid sitemap URL: %s", err)
+			continue
+		}
+
+		entries, err := sitemap.Fetch(c.client, c.config.userAgent(), sitemapUrl)
+		if err != nil {
+			logger.Warnf("Failed to fetch sitemap: %s", err)
+			continue
+		}
+
+		added := 0
+		for _, entry := range entries {
+			entryUrl, err := parent.Parse(entry.Loc)
+			if err != nil {
+				logger.Debugf("Invalid sitemap entry %q: %s", entry.Loc, err)
+				continue
+			}
+			req := frontier.NewRequest(entryUrl)
+			req.Parent = parent
+			req.LastMod = entry.LastMod
+			req.ChangeFreq = entry.ChangeFreq
+			req.Priority = entry.Priority
+			if c.frontier.Add(req) {
+				added++
+			}
+		}
+		logger.Infof("Seeded %d of %d sitemap URLs", added, len(entries))
+	}
+}
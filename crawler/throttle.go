@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latencySmoothing is the weight of a new latency sample in a host's average
+const latencySmoothing = 0.3
+
+// ThrottleConfig adapts the delay of each host to how it responds. A host
+// starts at its politeness delay. 429 and 503 responses double the delay and
+// their Retry-After is honoured, while other responses move the delay
+// halfway towards the host's average response time, so slow hosts are
+// fetched less often and fast ones gradually more often. Throttling is
+// disabled if MaxDelay is zero.
+type ThrottleConfig struct {
+	// MinDelay is the shortest delay a fast host speeds up to, its politeness
+	// delay if zero. A robots.txt Crawl-delay is never undercut.
+	MinDelay time.Duration
+	// MaxDelay is the longest delay, Retry-After included
+	MaxDelay time.Duration
+}
+
+// fetchFeedback is how a host responded to a fetch
+type fetchFeedback struct {
+	// fetched is false when no request was made
+	fetched bool
+	// status is 0 when the request failed without a response
+	status     int
+	retryAfter time.Duration
+	latency    time.Duration
+}
+
+// backoff reports whether the host asked the crawler to slow down
+func (f fetchFeedback) backoff() bool {
+	return f.status == http.StatusTooManyRequests || f.status == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP
+// date, returning 0 if it is missing or invalid
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// throttle adapts per-host delays, the scheduler's lock must be held
+type throttle struct {
+	config ThrottleConfig
+	// minDelay returns the shortest delay allowed for the host of a URL
+	minDelay func(u *url.URL) time.Duration
+}
+
+// adapt updates the delay of q after a fetch of u
+func (t *throttle) adapt(q *hostQueue, u *url.URL, feedback fetchFeedback, now time.Time) {
+	if feedback.latency > 0 {
+		if q.latency == 0 {
+			q.latency = feedback.latency
+		} else {
+			q.latency = time.Duration(latencySmoothing*float64(feedback.latency) + (1-latencySmoothing)*float64(q.latency))
+		}
+	}
+
+	delay := q.delay
+	toward := (q.delay + q.latency) / 2
+	switch {
+	case feedback.backoff():
+		q.throttled++
+		delay = max(2*q.delay, feedback.retryAfter)
+	case feedback.status == 0 || feedback.status >= http.StatusInternalServerError:
+		// Never speed up on errors
+		delay = max(q.delay, toward)
+	default:
+		delay = toward
+	}
+	floor := t.minDelay(u)
+	q.delay = min(max(delay, floor), max(t.config.MaxDelay, floor))
+
+	if feedback.backoff() {
+		q.nextFetch = maxTime(q.nextFetch, now.Add(q.delay))
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestParseRetryAfter tests parsing Retry-After in seconds and as a date
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"-3", 0},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 01 May 2024 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", tt.header, tt.expected, got)
+		}
+	}
+}
+
+// TestThrottleAdapt tests how a host's delay follows its responses
+func TestThrottleAdapt(t *testing.T) {
+	u, _ := url.Parse("https://example.com/")
+	throttle := &throttle{
+		config:   ThrottleConfig{MaxDelay: 8 * time.Second},
+		minDelay: func(*url.URL) time.Duration { return 500 * time.Millisecond },
+	}
+	q := &hostQueue{delay: time.Second, baseDelay: time.Second}
+	now := time.Now()
+
+	throttle.adapt(q, u, fetchFeedback{fetched: true, status: http.StatusTooManyRequests, latency: 100 * time.Millisecond}, now)
+	if q.delay != 2*time.Second || q.throttled != 1 {
+		t.Errorf("Expected 429 to double the delay to 2s, got %s", q.delay)
+	}
+	if q.nextFetch.Before(now.Add(2 * time.Second)) {
+		t.Errorf("Expected the next fetch to wait for the new delay, got %s", q.nextFetch.Sub(now))
+	}
+
+	throttle.adapt(q, u, fetchFeedback{fetched: true, status: http.StatusServiceUnavailable, retryAfter: time.Minute}, now)
+	if q.delay != 8*time.Second {
+		t.Errorf("Expected Retry-After to be capped at the max delay of 8s, got %s", q.delay)
+	}
+
+	throttle.adapt(q, u, fetchFeedback{fetched: true, status: http.StatusInternalServerError, latency: 100 * time.Millisecond}, now)
+	if q.delay != 8*time.Second {
+		t.Errorf("Expected errors not to speed up, got %s", q.delay)
+	}
+
+	previous := q.delay
+	for range 20 {
+		throttle.adapt(q, u, fetchFeedback{fetched: true, status: http.StatusOK, latency: 100 * time.Millisecond}, now)
+		if q.delay > previous {
+			t.Fatalf("Expected fast responses to speed up, went from %s to %s", previous, q.delay)
+		}
+		previous = q.delay
+	}
+	if q.delay != 500*time.Millisecond {
+		t.Errorf("Expected the delay to settle at the 500ms minimum, got %s", q.delay)
+	}
+
+	for range 5 {
+		throttle.adapt(q, u, fetchFeedback{fetched: true, status: http.StatusOK, latency: 4 * time.Second}, now)
+	}
+	if q.delay <= 2*time.Second {
+		t.Errorf("Expected slow responses to slow down, got %s", q.delay)
+	}
+}
+
+// TestCrawlerThrottle tests that a host answering 429 is slowed down and shows up in the host stats
+func TestCrawlerThrottle(t *testing.T) {
+	var mu sync.Mutex
+	fetchedAt := map[string]time.Time{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetchedAt[r.URL.Path] = time.Now()
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/busy">Busy</a><a href="/next">Next</a></body></html>`))
+		case "/busy":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>Next</body></html>`))
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	contentStorage, _ := storage.NewFileStorage(t.TempDir())
+	crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+		WorkerCount:     2,
+		PolitenessDelay: 10 * time.Millisecond,
+		RevisitDelay:    time.Hour,
+		Throttle:        ThrottleConfig{MinDelay: 10 * time.Millisecond, MaxDelay: 5 * time.Second},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		crawler.Start()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		crawler.Terminate()
+		t.Fatal("Crawler did not stop")
+	}
+
+	mu.Lock()
+	wait := fetchedAt["/next"].Sub(fetchedAt["/busy"])
+	mu.Unlock()
+	if wait < 900*time.Millisecond {
+		t.Errorf("Expected the Retry-After of 1s to be honoured, next fetch after %s", wait)
+	}
+
+	stats := crawler.HostStats()
+	if len(stats) != 1 || stats[0].Host != serverURL.Host {
+		t.Fatalf("Expected stats for %s, got %+v", serverURL.Host, stats)
+	}
+	if stats[0].Throttled != 1 || stats[0].BaseDelay != 10*time.Millisecond {
+		t.Errorf("Unexpected host stats: %+v", stats[0])
+	}
+}
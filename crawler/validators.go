@@ -0,0 +1,249 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Validators are the cache validators a page was last served with, sent
+// back on its next fetch so an unchanged page is answered with 304 Not
+// Modified instead of its body
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ValidatorStore keeps the validators of each fetched page by canonical key.
+// It must be safe for concurrent use.
+type ValidatorStore interface {
+	Get(key string) (Validators, bool)
+	Put(key string, validators Validators) error
+}
+
+// memoryValidators is the default ValidatorStore, kept for one crawl
+type memoryValidators struct {
+	mu         sync.Mutex
+	validators map[string]Validators
+}
+
+func newMemoryValidators() *memoryValidators {
+	return &memoryValidators{validators: make(map[string]Validators)}
+}
+
+func (m *memoryValidators) Get(key string) (Validators, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	validators, ok := m.validators[key]
+	return validators, ok
+}
+
+func (m *memoryValidators) Put(key string, validators Validators) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validators[key] = validators
+	return nil
+}
+
+// validatorLine is one line of a validator file
+type validatorLine struct {
+	Key string `json:"key"`
+	Validators
+}
+
+// ValidatorFile is a ValidatorStore kept in memory and appended to a JSONL
+// file, one line per change, so validators outlive the crawl. Later lines
+// replace earlier ones for the same key, the file is compacted when opened.
+type ValidatorFile struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	validators map[string]Validators
+}
+
+// OpenValidatorFile loads the validator file at path, creating it if needed
+func OpenValidatorFile(path string) (*ValidatorFile, error) {
+	validators, lines, err := readValidators(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &ValidatorFile{path: path, validators: validators}
+	if lines > len(validators) {
+		if err := f.compact(); err != nil {
+			return nil, err
+		}
+	}
+	f.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening validator file: %w", err)
+	}
+	return f, nil
+}
+
+// readValidators reads the validator file at path and the number of lines
+// it holds. A missing file holds no validators.
+func readValidators(path string) (map[string]Validators, int, error) {
+	validators := make(map[string]Validators)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return validators, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening validator file: %w", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var line validatorLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return nil, 0, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		validators[line.Key] = line.Validators
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("reading validator file: %w", err)
+	}
+	return validators, lines, nil
+}
+
+// compact rewrites the file with one line per key, replacing it atomically
+func (f *ValidatorFile) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".validators-*")
+	if err != nil {
+		return fmt.Errorf("compacting validator file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for key, validators := range f.validators {
+		line, err := json.Marshal(validatorLine{Key: key, Validators: validators})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compacting validator file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compacting validator file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("compacting validator file: %w", err)
+	}
+	return nil
+}
+
+func (f *ValidatorFile) Get(key string) (Validators, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	validators, ok := f.validators[key]
+	return validators, ok
+}
+
+// Put records validators for key, only writing a line if they changed
+func (f *ValidatorFile) Put(key string, validators Validators) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if current, ok := f.validators[key]; ok && current == validators {
+		return nil
+	}
+	f.validators[key] = validators
+	line, err := json.Marshal(validatorLine{Key: key, Validators: validators})
+	if err != nil {
+		return err
+	}
+	// One write per record keeps lines whole
+	if _, err := f.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing validator file: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of pages with validators
+func (f *ValidatorFile) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.validators)
+}
+
+func (f *ValidatorFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// conditional makes page fetches conditional on the validators stored for
+// their URL
+type conditional struct {
+	store ValidatorStore
+	key   func(*url.URL) string
+}
+
+// apply adds If-None-Match and If-Modified-Since to a request for u when
+// validators are stored for it
+func (c *conditional) apply(httpReq *http.Request, u *url.URL) {
+	validators, ok := c.store.Get(c.key(u))
+	if !ok {
+		return
+	}
+	if validators.ETag != "" {
+		httpReq.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		httpReq.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+}
+
+// update stores the validators of a response for u with status and header.
+// A 304 may carry only the validators that changed, the others are kept.
+func (c *conditional) update(u *url.URL, status int, header http.Header) error {
+	validators := Validators{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	key := c.key(u)
+	switch {
+	case status == http.StatusNotModified:
+		stored, _ := c.store.Get(key)
+		if validators.ETag == "" {
+			validators.ETag = stored.ETag
+		}
+		if validators.LastModified == "" {
+			validators.LastModified = stored.LastModified
+		}
+	case status < 200 || status >= 300:
+		return nil
+	}
+	if validators == (Validators{}) {
+		if _, ok := c.store.Get(key); !ok {
+			return nil
+		}
+	}
+	return c.store.Put(key, validators)
+}
+
+// forget makes the next fetch of u unconditional, e.g. because the stored
+// copy an unchanged page would be read from is missing
+func (c *conditional) forget(u *url.URL) error {
+	key := c.key(u)
+	if _, ok := c.store.Get(key); !ok {
+		return nil
+	}
+	return c.store.Put(key, Validators{})
+}
@@ -0,0 +1,193 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/storage"
+)
+
+// TestValidatorFile tests that validators outlive the file and superseded lines are compacted away
+func TestValidatorFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "validators.jsonl")
+	file, err := OpenValidatorFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Put("https://example.com/", Validators{ETag: `"v1"`})
+	file.Put("https://example.com/", Validators{ETag: `"v1"`})
+	file.Put("https://example.com/", Validators{ETag: `"v2"`})
+	file.Put("https://example.com/about", Validators{LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"})
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("Expected unchanged validators not to be written again, got %d lines", lines)
+	}
+
+	file, err = OpenValidatorFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if file.Len() != 2 {
+		t.Errorf("Expected validators for 2 pages, got %d", file.Len())
+	}
+	if validators, _ := file.Get("https://example.com/"); validators.ETag != `"v2"` {
+		t.Errorf("Expected the latest ETag, got %+v", validators)
+	}
+	if validators, _ := file.Get("https://example.com/about"); validators.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("Expected the Last-Modified date, got %+v", validators)
+	}
+	data, _ = os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("Expected the file to be compacted to 2 lines, got %d", lines)
+	}
+}
+
+// countingStorage counts the writes to a storage
+type countingStorage struct {
+	storage.Storage
+	mu   sync.Mutex
+	sets int
+}
+
+func (s *countingStorage) Set(filePath string, value string) error {
+	s.mu.Lock()
+	s.sets++
+	s.mu.Unlock()
+	return s.Storage.Set(filePath, value)
+}
+
+// TestCrawlerConditionalRequests tests that a recrawl sends the stored validators and skips unchanged pages
+func TestCrawlerConditionalRequests(t *testing.T) {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	pages := map[string]string{
+		"/":            `<a href="/page">Page</a><a href="/list?page=1">1</a><a href="/list?page=2">2</a><a href="/file.txt">File</a>`,
+		"/page":        `Page`,
+		"/list?page=1": `<a href="/a">A</a>`,
+		"/list?page=2": `<a href="/b">B</a>`,
+		"/a":           `A`,
+		"/b":           `B`,
+	}
+	var mu sync.Mutex
+	// statuses holds the statuses served per request URI, in order
+	statuses := map[string][]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uri := r.URL.RequestURI()
+		status := http.StatusOK
+		switch uri {
+		case "/robots.txt":
+			status = http.StatusNotFound
+		case "/a", "/b":
+			// No validators, always downloaded
+		case "/page":
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				status = http.StatusNotModified
+			}
+		default:
+			etag := `"` + uri + `"`
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				status = http.StatusNotModified
+			}
+		}
+		mu.Lock()
+		statuses[uri] = append(statuses[uri], status)
+		mu.Unlock()
+		if uri == "/file.txt" {
+			w.Header().Set("Content-Type", "text/plain")
+		} else {
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`<html><body>` + pages[uri] + `</body></html>`))
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	dir := t.TempDir()
+	fileStorage, _ := storage.NewFileStorage(dir)
+	// The validators are kept outside the output directory
+	path := filepath.Join(t.TempDir(), "validators.jsonl")
+	crawl := func() (notModified int, writes int) {
+		validators, err := OpenValidatorFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer validators.Close()
+		contentStorage := &countingStorage{Storage: fileStorage}
+		crawler := NewCrawler([]url.URL{*serverURL}, contentStorage, &Config{
+			WorkerCount:     1,
+			PolitenessDelay: time.Millisecond,
+			RevisitDelay:    time.Hour,
+			Validators:      validators,
+		})
+		crawler.AddProcessor(&TestProcessor{callback: func(result *CrawlResult) error {
+			if result.NotModified {
+				mu.Lock()
+				notModified++
+				mu.Unlock()
+			}
+			return nil
+		}})
+
+		done := make(chan struct{})
+		go func() {
+			crawler.Start()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			crawler.Terminate()
+			t.Fatal("Crawler did not stop")
+		}
+		return notModified, contentStorage.sets
+	}
+
+	if notModified, writes := crawl(); notModified != 0 || writes != 6 {
+		t.Errorf("First crawl: expected 6 pages stored, got %d stored and %d unchanged", writes, notModified)
+	}
+	// The links of unchanged pages come from their stored copies, so /a and
+	// /b are still found through the pages of the list
+	if notModified, writes := crawl(); notModified != 4 || writes != 2 {
+		t.Errorf("Recrawl: expected 4 unchanged pages and 2 writes, got %d unchanged and %d writes", notModified, writes)
+	}
+
+	// Without its stored copy an unchanged page is downloaded again
+	if err := os.Remove(filepath.Join(dir, serverURL.Host, "page.json")); err != nil {
+		t.Fatal(err)
+	}
+	crawl()
+	crawl()
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := map[string][]int{
+		"/":            {200, 304, 304, 304},
+		"/page":        {200, 304, 304, 200},
+		"/list?page=1": {200, 304, 304, 304},
+		"/list?page=2": {200, 304, 304, 304},
+		"/a":           {200, 200, 200, 200},
+		"/b":           {200, 200, 200, 200},
+		// Only pages with a stored copy are fetched conditionally
+		"/file.txt": {200, 200, 200, 200},
+	}
+	for uri, want := range expected {
+		if got := statuses[uri]; !slices.Equal(got, want) {
+			t.Errorf("%s: expected statuses %v, got %v", uri, want, got)
+		}
+	}
+}
@@ -1,48 +1,109 @@
 package crawler
 
 import (
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/Fardin-E/web_crawler.git/frontier"
 	"github.com/Fardin-E/web_crawler.git/parser"
 	log "github.com/sirupsen/logrus"
 )
 
 type CrawlResult struct {
-	Url         *url.URL
+	// Request is the frontier request the result was fetched for
+	Request *frontier.Request
+	Url     *url.URL
+	// FinalUrl is the URL the response was served from after redirects
+	FinalUrl *url.URL
+	// Redirects are the hops from Url to FinalUrl, empty if there were none.
+	// When a redirect was not followed because the frontier refused its
+	// target, the result is the redirect response itself: FinalUrl is the
+	// URL that answered with it and the last hop is the one not followed.
+	Redirects []Redirect
+	// StatusCode, Status, Proto and Header are those of the final response,
+	// whatever its status
+	StatusCode  int
+	Status      string
+	Proto       string
+	Header      http.Header
 	ContentType string
 	Body        []byte
 	Info        *parser.Info
+	// FetchedAt is when the fetch started, Duration how long it took with
+	// redirects and reading the body
+	FetchedAt time.Time
+	Duration  time.Duration
+	// NotModified is set when the page was answered with 304 Not Modified
+	// to a conditional request, it is unchanged since it was last fetched
+	// and Body is empty
+	NotModified bool
 }
 
+// Successful reports whether the page was served with a 2xx status. Results
+// without a status code, e.g. built by hand, count as successful.
+func (r *CrawlResult) Successful() bool {
+	return r.StatusCode == 0 || (r.StatusCode >= 200 && r.StatusCode < 300)
+}
+
+// PolitenessFunc returns the minimum time between two fetches to the host of a URL
+type PolitenessFunc func(u *url.URL) time.Duration
+
 type Worker struct {
-	input      chan *url.URL
-	deadLetter chan *url.URL
+	input      chan *frontier.Request
+	deadLetter chan *frontier.Request
 	result     chan CrawlResult
 	done       chan struct{}
 	id         int
 	logger     *log.Entry
 
 	// Only contains the host part of the URL
-	history map[string]time.Time
+	history       map[string]time.Time
+	politeness    PolitenessFunc
+	fetcher       Fetcher
+	maxRedirects  int
+	checkRedirect RedirectFunc
+	// budget is nil when the crawl has no page or byte limits
+	budget *budget
+	// scheduler, if set, is told when the worker is done with a request
+	scheduler *hostScheduler
+	// retrier, if set, decides whether failed requests are retried or
+	// dead-lettered
+	retrier *retrier
+	// conditional, if set, makes fetches conditional on the validators of
+	// the previous fetch
+	conditional *conditional
 }
 
-func NewWorker(input chan *url.URL, result chan CrawlResult, done chan struct{}, id int, deadLetter chan *url.URL) *Worker {
+func NewWorker(input chan *frontier.Request, result chan CrawlResult, done chan struct{}, id int, deadLetter chan *frontier.Request) *Worker {
 	history := make(map[string]time.Time)
 	logger := log.WithField("worker", id)
 	return &Worker{
-		input:      input,
-		result:     result,
-		done:       done,
-		id:         id,
-		history:    history,
-		deadLetter: deadLetter,
-		logger:     logger,
+		input:        input,
+		result:       result,
+		done:         done,
+		id:           id,
+		history:      history,
+		deadLetter:   deadLetter,
+		logger:       logger,
+		politeness:   func(*url.URL) time.Duration { return DefaultPolitenessDelay },
+		fetcher:      NewHTTPFetcher(nil),
+		maxRedirects: DefaultMaxRedirects,
 	}
 }
+
+// SetPoliteness replaces the fixed DefaultPolitenessDelay between fetches to a host
+func (w *Worker) SetPoliteness(politeness PolitenessFunc) {
+	w.politeness = politeness
+}
+
+// SetFetcher replaces the plain HTTP fetcher, e.g. with one wrapped in middleware
+func (w *Worker) SetFetcher(fetcher Fetcher) {
+	w.fetcher = fetcher
+}
+
 func (w *Worker) Start() {
 	w.logger.Debugf("Worker %d started", w.id)
 	defer func() {
@@ -52,20 +113,17 @@ func (w *Worker) Start() {
 
 	for {
 		select {
-		case url, ok := <-w.input:
+		case req, ok := <-w.input:
 			// Check if channel is closed
 			if !ok {
 				w.logger.Debug("Input channel closed, worker exiting")
 				return
 			}
 
-			content, err := w.fetch(url)
-			if err != nil {
-				log.Errorf("Worker %d error fetching content: %s", w.id, err)
-				w.deadLetter <- url
-				continue
+			feedback := w.handle(req)
+			if w.scheduler != nil {
+				w.scheduler.release(req, feedback)
 			}
-			w.result <- content
 		case <-w.done:
 			w.logger.Debug("Received done signal, worker exiting")
 			return
@@ -73,33 +131,93 @@ func (w *Worker) Start() {
 	}
 }
 
+// handle fetches req and passes on the result, or dead-letters req if it
+// could not be fetched. It returns how the host responded.
+func (w *Worker) handle(req *frontier.Request) fetchFeedback {
+	// The crawl is stopping, the request is left unfinished so a
+	// persistent frontier fetches it when the crawl is resumed
+	if w.budget != nil && !w.budget.reserve() {
+		return fetchFeedback{}
+	}
+	content, feedback, err := w.fetch(req)
+	var redirectErr *RedirectError
+	if errors.As(err, &redirectErr) && redirectErr.Refused {
+		w.logger.WithField("reason", redirectErr.Reason).Infof("Not following redirect from %s to %s", req.Url, redirectErr.Target)
+		w.result <- content
+		return feedback
+	}
+	if err != nil {
+		log.Errorf("Worker %d error fetching content: %s", w.id, err)
+		if w.retrier != nil {
+			w.retrier.failed(req, err, feedback)
+		} else {
+			w.deadLetter <- req
+		}
+		return feedback
+	}
+	// A retryable status is fetched again rather than passed on
+	if w.retrier != nil && w.retrier.completed(req, &content, feedback) {
+		return feedback
+	}
+	if w.budget != nil {
+		w.budget.record(len(content.Body))
+	}
+	w.result <- content
+	return feedback
+}
+
 func (w *Worker) CheckPoliteness(url *url.URL) bool {
+	return w.politenessWait(url) <= 0
+}
+
+// politenessWait returns how long to wait before url's host may be fetched again
+func (w *Worker) politenessWait(url *url.URL) time.Duration {
 	if lastFetch, ok := w.history[url.Host]; ok {
-		return time.Since(lastFetch) > 2*time.Second
+		return w.politeness(url) - time.Since(lastFetch)
 	}
-	return true
+	return 0
 }
 
-func (w *Worker) fetch(url *url.URL) (CrawlResult, error) {
-	w.logger.Debugf("Worker %d fetching %s", w.id, url)
+func (w *Worker) fetch(req *frontier.Request) (CrawlResult, fetchFeedback, error) {
+	url := req.Url
+	w.logger.WithField("depth", req.Depth).Debugf("Worker %d fetching %s", w.id, url)
 	defer func() {
 		w.history[url.Host] = time.Now()
 	}()
 	for !w.CheckPoliteness(url) {
-		time.Sleep(2 * time.Second)
+		time.Sleep(w.politenessWait(url))
+	}
+	start := time.Now()
+	feedback := fetchFeedback{fetched: true}
+	res, finalUrl, redirects, err := w.follow(req)
+	var redirectErr *RedirectError
+	if errors.As(err, &redirectErr) && redirectErr.Refused {
+		feedback.status = res.StatusCode
+		feedback.latency = time.Since(start)
+		return CrawlResult{
+			Request:    req,
+			Url:        url,
+			FinalUrl:   finalUrl,
+			Redirects:  redirects,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Proto:      res.Proto,
+			Header:     res.Header,
+			FetchedAt:  start,
+			Duration:   time.Since(start),
+		}, feedback, err
 	}
-	res, err := http.Get(url.String())
 	if err != nil {
-		return CrawlResult{}, err
+		return CrawlResult{}, feedback, err
 	}
 	defer res.Body.Close()
+	feedback.status = res.StatusCode
+	feedback.latency = time.Since(start)
+	feedback.retryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
 
-	if res.StatusCode != http.StatusOK {
-		return CrawlResult{}, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
-	}
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return CrawlResult{}, err
+		return CrawlResult{}, feedback, err
 	}
 
 	var inferredContentType string
@@ -111,8 +229,18 @@ func (w *Worker) fetch(url *url.URL) (CrawlResult, error) {
 	}
 
 	return CrawlResult{
+		Request:     req,
 		Url:         url,
+		FinalUrl:    finalUrl,
+		Redirects:   redirects,
+		StatusCode:  res.StatusCode,
+		Status:      res.Status,
+		Proto:       res.Proto,
+		Header:      res.Header,
 		ContentType: inferredContentType,
 		Body:        body,
-	}, nil
+		FetchedAt:   start,
+		Duration:    time.Since(start),
+		NotModified: res.StatusCode == http.StatusNotModified,
+	}, feedback, nil
 }
@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"testing"
 	"time"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
 )
 
 // TestWorkerFetch tests the worker's ability to fetch URLs
@@ -25,10 +27,10 @@ func TestWorkerFetch(t *testing.T) {
 	}
 
 	// Create worker
-	input := make(chan *url.URL, 1)
+	input := make(chan *frontier.Request, 1)
 	result := make(chan CrawlResult, 1)
 	done := make(chan struct{})
-	deadLetter := make(chan *url.URL, 1)
+	deadLetter := make(chan *frontier.Request, 1)
 
 	worker := NewWorker(input, result, done, 0, deadLetter)
 
@@ -36,7 +38,7 @@ func TestWorkerFetch(t *testing.T) {
 	go worker.Start()
 
 	// Send URL to worker
-	input <- testURL
+	input <- frontier.NewRequest(testURL)
 
 	// Wait for result
 	select {
@@ -65,16 +67,16 @@ func TestWorkerErrorHandling(t *testing.T) {
 	// Create a URL that will fail (invalid host)
 	badURL, _ := url.Parse("http://this-host-does-not-exist-12345.com")
 
-	input := make(chan *url.URL, 1)
+	input := make(chan *frontier.Request, 1)
 	result := make(chan CrawlResult, 1)
 	done := make(chan struct{})
-	deadLetter := make(chan *url.URL, 1)
+	deadLetter := make(chan *frontier.Request, 1)
 
 	worker := NewWorker(input, result, done, 0, deadLetter)
 	go worker.Start()
 
 	// Send bad URL
-	input <- badURL
+	input <- frontier.NewRequest(badURL)
 
 	// Should receive in dead letter channel
 	select {
@@ -92,10 +94,10 @@ func TestWorkerErrorHandling(t *testing.T) {
 
 // TestWorkerGracefulShutdown tests that worker exits cleanly when input channel closes
 func TestWorkerGracefulShutdown(t *testing.T) {
-	input := make(chan *url.URL)
+	input := make(chan *frontier.Request)
 	result := make(chan CrawlResult, 1)
 	done := make(chan struct{})
-	deadLetter := make(chan *url.URL, 1)
+	deadLetter := make(chan *frontier.Request, 1)
 
 	worker := NewWorker(input, result, done, 0, deadLetter)
 
@@ -128,16 +130,16 @@ func TestWorkerPolitenessDelay(t *testing.T) {
 
 	testURL, _ := url.Parse(server.URL)
 
-	input := make(chan *url.URL, 2)
+	input := make(chan *frontier.Request, 2)
 	result := make(chan CrawlResult, 2)
 	done := make(chan struct{})
-	deadLetter := make(chan *url.URL, 1)
+	deadLetter := make(chan *frontier.Request, 1)
 
 	worker := NewWorker(input, result, done, 0, deadLetter)
 	go worker.Start()
 
 	// Send same URL twice
-	input <- testURL
+	input <- frontier.NewRequest(testURL)
 
 	start := time.Now()
 
@@ -145,7 +147,7 @@ func TestWorkerPolitenessDelay(t *testing.T) {
 	<-result
 
 	// Send second request to same host
-	input <- testURL
+	input <- frontier.NewRequest(testURL)
 
 	// Wait for second result
 	<-result
@@ -161,48 +163,54 @@ func TestWorkerPolitenessDelay(t *testing.T) {
 	close(done)
 }
 
-// TestWorkerHTTPStatusCodes tests handling of different HTTP status codes
+// TestWorkerHTTPStatusCodes tests that responses of any status code are passed on as results
 func TestWorkerHTTPStatusCodes(t *testing.T) {
 	tests := []struct {
-		name          string
-		statusCode    int
-		shouldSucceed bool
+		name       string
+		statusCode int
+		successful bool
 	}{
 		{"200 OK", http.StatusOK, true},
 		{"404 Not Found", http.StatusNotFound, false},
+		{"410 Gone", http.StatusGone, false},
 		{"500 Server Error", http.StatusInternalServerError, false},
-		{"301 Redirect", http.StatusMovedPermanently, false},
+		{"301 Redirect without Location", http.StatusMovedPermanently, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create test server with specific status code
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "yes")
 				w.WriteHeader(tt.statusCode)
 				w.Write([]byte("Response"))
 			}))
 			defer server.Close()
 
 			testURL, _ := url.Parse(server.URL)
-			input := make(chan *url.URL, 1)
+			input := make(chan *frontier.Request, 1)
 			result := make(chan CrawlResult, 1)
 			done := make(chan struct{})
-			deadLetter := make(chan *url.URL, 1)
+			deadLetter := make(chan *frontier.Request, 1)
 
 			worker := NewWorker(input, result, done, 0, deadLetter)
 			go worker.Start()
 
-			input <- testURL
+			input <- frontier.NewRequest(testURL)
 
 			select {
-			case <-result:
-				if !tt.shouldSucceed {
-					t.Error("Expected error but got result")
+			case res := <-result:
+				if res.StatusCode != tt.statusCode || res.Successful() != tt.successful {
+					t.Errorf("Expected status %d, successful %v, got %d, %v", tt.statusCode, tt.successful, res.StatusCode, res.Successful())
 				}
-			case <-deadLetter:
-				if tt.shouldSucceed {
-					t.Error("Expected result but got dead letter")
+				if res.Header.Get("X-Test") != "yes" || string(res.Body) != "Response" || res.Proto != "HTTP/1.1" {
+					t.Errorf("Expected the response headers, body and protocol, got %v %q %q", res.Header, res.Body, res.Proto)
 				}
+				if res.FetchedAt.IsZero() || res.Duration <= 0 {
+					t.Errorf("Expected fetch timing, got %s after %s", res.Duration, res.FetchedAt)
+				}
+			case <-deadLetter:
+				t.Error("Expected result but got dead letter")
 			case <-time.After(3 * time.Second):
 				t.Fatal("Timeout")
 			}
@@ -0,0 +1,278 @@
+package frontier
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultFalsePositiveRate is the BloomFilter false positive rate when none is set
+const DefaultFalsePositiveRate = 0.001
+
+// BloomFilter is a fixed-size probabilistic set. Contains never reports a
+// false negative and reports false positives at roughly the rate the filter
+// was sized for, as long as no more than its capacity is added.
+type BloomFilter struct {
+	words    []uint64
+	bits     uint64
+	hashes   int
+	setBits  uint64
+	added    uint64
+	capacity uint64
+	seeds    [2]maphash.Seed
+}
+
+// BloomStats describes how full a BloomFilter is
+type BloomStats struct {
+	Bits     uint64
+	Hashes   int
+	Capacity uint64
+	// Added counts distinct additions, a key added again is not counted
+	// unless it was not contained before
+	Added uint64
+	// FillRatio is the fraction of bits set, the filter is optimally full at 0.5
+	FillRatio float64
+	// FalsePositiveRate is the false positive rate estimated from FillRatio
+	FalsePositiveRate float64
+}
+
+func (s BloomStats) String() string {
+	return fmt.Sprintf("%d/%d added, %.1f%% full, ~%.4f%% false positives",
+		s.Added, s.Capacity, 100*s.FillRatio, 100*s.FalsePositiveRate)
+}
+
+// NewBloomFilter sizes a filter for capacity items at the given false
+// positive rate, DefaultFalsePositiveRate if not between 0 and 1
+func NewBloomFilter(capacity uint64, falsePositiveRate float64) *BloomFilter {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = DefaultFalsePositiveRate
+	}
+	capacity = max(capacity, 1)
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	m = max((m+63)/64*64, 64)
+	k := max(int(math.Round(float64(m)/float64(capacity)*math.Ln2)), 1)
+	return &BloomFilter{
+		words:    make([]uint64, m/64),
+		bits:     m,
+		hashes:   k,
+		capacity: capacity,
+		seeds:    [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+}
+
+// locations calls yield with the k bit positions of key, found by double hashing
+func (b *BloomFilter) locations(key string, yield func(uint64) bool) {
+	h1 := maphash.String(b.seeds[0], key)
+	h2 := maphash.String(b.seeds[1], key) | 1
+	for i := range b.hashes {
+		if !yield((h1 + uint64(i)*h2) % b.bits) {
+			return
+		}
+	}
+}
+
+// Add inserts key into the set
+func (b *BloomFilter) Add(key string) {
+	if b.Contains(key) {
+		return
+	}
+	b.added++
+	b.locations(key, func(bit uint64) bool {
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.words[word]&mask == 0 {
+			b.words[word] |= mask
+			b.setBits++
+		}
+		return true
+	})
+}
+
+// Contains reports whether key may have been added
+func (b *BloomFilter) Contains(key string) bool {
+	contains := true
+	b.locations(key, func(bit uint64) bool {
+		contains = b.words[bit/64]&(uint64(1)<<(bit%64)) != 0
+		return contains
+	})
+	return contains
+}
+
+// Reset empties the filter
+func (b *BloomFilter) Reset() {
+	clear(b.words)
+	b.setBits = 0
+	b.added = 0
+}
+
+func (b *BloomFilter) Stats() BloomStats {
+	fill := float64(b.setBits) / float64(b.bits)
+	return BloomStats{
+		Bits:              b.bits,
+		Hashes:            b.hashes,
+		Capacity:          b.capacity,
+		Added:             b.added,
+		FillRatio:         fill,
+		FalsePositiveRate: math.Pow(fill, float64(b.hashes)),
+	}
+}
+
+// BloomHistory is a History for very large crawls. Recently queued URLs are
+// kept in an exact map with their revisit time, up to maxRecent entries.
+// URLs that fall out of the map before they are due are added to a Bloom
+// filter for the window their revisit time falls in, and are considered seen
+// until that window ends. Filters of windows that have ended are dropped, so
+// a URL is forgotten once it is due, like an exact History forgets it.
+//
+// A URL only found in the filters may therefore be revisited up to a window
+// after its exact revisit delay, every window URLs are due in holds its own
+// filter, and a small fraction of new URLs is wrongly considered seen.
+type BloomHistory struct {
+	// mu lets Stats be called while the frontier uses the history
+	mu        sync.Mutex
+	recent    memoryHistory
+	order     []string
+	maxRecent int
+	window    time.Duration
+
+	capacity          uint64
+	falsePositiveRate float64
+	// filters are keyed by the index of the window, since the Unix epoch,
+	// the revisit times of their URLs fall in
+	filters map[int64]*BloomFilter
+}
+
+// BloomHistoryStats describes a BloomHistory
+type BloomHistoryStats struct {
+	Recent int
+	// Filters is the number of windows with URLs in a filter
+	Filters int
+	// Fullest is the filter with the highest FillRatio
+	Fullest BloomStats
+}
+
+// NewBloomHistory creates a BloomHistory whose filters each hold capacity
+// URLs at falsePositiveRate, keeping the maxRecent most recent URLs exactly
+// and grouping the others by window of revisit time
+func NewBloomHistory(capacity uint64, falsePositiveRate float64, maxRecent int, window time.Duration) *BloomHistory {
+	if window <= 0 {
+		window = DefaultRevisitDelay
+	}
+	return &BloomHistory{
+		recent:            memoryHistory{},
+		maxRecent:         maxRecent,
+		window:            window,
+		capacity:          capacity,
+		falsePositiveRate: falsePositiveRate,
+		filters:           make(map[int64]*BloomFilter),
+	}
+}
+
+// windowEnd returns when the window with the given index ends
+func (h *BloomHistory) windowEnd(index int64) time.Time {
+	return time.Unix(0, (index+1)*int64(h.window))
+}
+
+func (h *BloomHistory) Get(key string) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if revisitAt, ok := h.recent.Get(key); ok {
+		return revisitAt, true
+	}
+	// The latest window wins, the URL may have been queued again since
+	found, latest := false, int64(0)
+	for index, filter := range h.filters {
+		if (!found || index > latest) && filter.Contains(key) {
+			found, latest = true, index
+		}
+	}
+	if !found {
+		return time.Time{}, false
+	}
+	return h.windowEnd(latest), true
+}
+
+func (h *BloomHistory) Put(key string, revisitAt time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.recent[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.recent[key] = revisitAt
+	// Move the oldest exact entries to the filter of their window, entries
+	// already due need not be remembered
+	now := time.Now()
+	for h.maxRecent > 0 && len(h.recent) > h.maxRecent && len(h.order) > 0 {
+		oldest := h.order[0]
+		if revisitAt := h.recent[oldest]; revisitAt.After(now) {
+			index := revisitAt.UnixNano() / int64(h.window)
+			filter, ok := h.filters[index]
+			if !ok {
+				filter = NewBloomFilter(h.capacity, h.falsePositiveRate)
+				h.filters[index] = filter
+			}
+			filter.Add(oldest)
+		}
+		delete(h.recent, oldest)
+		h.order[0] = ""
+		h.order = h.order[1:]
+	}
+	return nil
+}
+
+// Evict drops expired exact entries and the filters of windows that have ended
+func (h *BloomHistory) Evict(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recent.Evict(now)
+	// Keep order in step with the map
+	kept := h.order[:0]
+	for _, key := range h.order {
+		if _, ok := h.recent[key]; ok {
+			kept = append(kept, key)
+		}
+	}
+	clear(h.order[len(kept):])
+	h.order = kept
+
+	for index := range h.filters {
+		if !now.Before(h.windowEnd(index)) {
+			delete(h.filters, index)
+		}
+	}
+}
+
+// Len returns the number of URLs remembered, counting a URL queued again
+// after it fell out of the exact map twice
+func (h *BloomHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := len(h.recent)
+	for _, filter := range h.filters {
+		n += int(filter.Stats().Added)
+	}
+	return n
+}
+
+func (h *BloomHistory) Close() error {
+	return nil
+}
+
+// Stats returns the fill of the fullest filter, so the capacity can be
+// raised once FillRatio nears or passes 0.5
+func (h *BloomHistory) Stats() BloomHistoryStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := BloomHistoryStats{
+		Recent:  len(h.recent),
+		Filters: len(h.filters),
+		Fullest: BloomStats{Capacity: max(h.capacity, 1)},
+	}
+	for _, filter := range h.filters {
+		if filterStats := filter.Stats(); filterStats.FillRatio >= stats.Fullest.FillRatio {
+			stats.Fullest = filterStats
+		}
+	}
+	return stats
+}
@@ -0,0 +1,133 @@
+package frontier
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+	"time"
+)
+
+// TestBloomFilter tests membership, the false positive rate and fill statistics
+func TestBloomFilter(t *testing.T) {
+	const capacity = 10000
+	filter := NewBloomFilter(capacity, 0.01)
+
+	for i := range capacity {
+		filter.Add(fmt.Sprintf("https://example.com/%d", i))
+	}
+	for i := range capacity {
+		if !filter.Contains(fmt.Sprintf("https://example.com/%d", i)) {
+			t.Fatalf("False negative for URL %d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range capacity {
+		if filter.Contains(fmt.Sprintf("https://other.com/%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / capacity; rate > 0.02 {
+		t.Errorf("Expected a false positive rate near 1%%, got %.2f%%", 100*rate)
+	}
+
+	stats := filter.Stats()
+	setBits := 0
+	for _, word := range filter.words {
+		setBits += bits.OnesCount64(word)
+	}
+	if stats.FillRatio != float64(setBits)/float64(stats.Bits) {
+		t.Errorf("Fill ratio %v does not match the set bits", stats.FillRatio)
+	}
+	if stats.FillRatio < 0.4 || stats.FillRatio > 0.6 {
+		t.Errorf("Expected a filter at capacity to be about half full, got %v", stats.FillRatio)
+	}
+	// A few keys are false positives when added and are not counted
+	if stats.Added > capacity || stats.Added < capacity*99/100 {
+		t.Errorf("Expected about %d additions, got %d", capacity, stats.Added)
+	}
+	filter.Add("https://example.com/1")
+	if filter.Stats().Added != stats.Added {
+		t.Error("Adding a key again should not be counted")
+	}
+
+	filter.Reset()
+	if filter.Contains("https://example.com/1") || filter.Stats().FillRatio != 0 {
+		t.Error("Reset filter should be empty")
+	}
+}
+
+// TestBloomHistory tests the exact recent map, the filter fallback and eviction
+func TestBloomHistory(t *testing.T) {
+	const window = time.Hour
+	history := NewBloomHistory(1000, 0.001, 2, window)
+	now := time.Now()
+	revisitAt := now.Add(time.Minute)
+	for _, key := range []string{"a", "b", "c"} {
+		history.Put(key, revisitAt)
+	}
+
+	if got, ok := history.Get("c"); !ok || !got.Equal(revisitAt) {
+		t.Errorf("Recent entry should have its exact revisit time, got %v", got)
+	}
+	got, ok := history.Get("a")
+	if !ok || got.Before(revisitAt) || got.After(revisitAt.Add(window)) {
+		t.Errorf("Entry only in the filter should be due within a window of its revisit time, got %v", got)
+	}
+	if _, ok := history.Get("d"); ok {
+		t.Error("Entry never added should not be seen")
+	}
+	if stats := history.Stats(); stats.Recent != 2 || stats.Filters != 1 || stats.Fullest.Added != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Queuing again neither grows the count nor forgets the new revisit time
+	history.Put("c", revisitAt)
+	if n := history.Len(); n != 3 {
+		t.Errorf("Expected 3 URLs, got %d", n)
+	}
+
+	// Once due, entries are forgotten, whether exact or in a filter
+	history.Evict(revisitAt.Add(window))
+	for _, key := range []string{"a", "c"} {
+		if _, ok := history.Get(key); ok {
+			t.Errorf("Entry %s should be forgotten once due", key)
+		}
+	}
+	if stats := history.Stats(); stats.Recent != 0 || stats.Filters != 0 {
+		t.Errorf("Expected everything to be evicted, got %+v", stats)
+	}
+}
+
+// TestBloomHistoryRevisitDelays tests that entries dropped from the exact
+// map keep their own revisit time rather than one shared by the filter
+func TestBloomHistoryRevisitDelays(t *testing.T) {
+	const window = time.Hour
+	history := NewBloomHistory(1000, 0.001, 1, window)
+	now := time.Now()
+	history.Put("daily", now.Add(24*time.Hour))
+	history.Put("hourly", now.Add(time.Hour))
+	history.Put("recent", now.Add(time.Minute))
+
+	for key, revisitAt := range map[string]time.Time{"daily": now.Add(24 * time.Hour), "hourly": now.Add(time.Hour)} {
+		got, ok := history.Get(key)
+		if !ok || got.Before(revisitAt) || got.After(revisitAt.Add(window)) {
+			t.Errorf("Entry %s should be due within a window of %v, got %v", key, revisitAt, got)
+		}
+	}
+
+	history.Evict(now.Add(3 * time.Hour))
+	if _, ok := history.Get("hourly"); ok {
+		t.Error("Entry should be forgotten once its window has ended")
+	}
+	if _, ok := history.Get("daily"); !ok {
+		t.Error("Entry should be remembered until its own revisit time")
+	}
+
+	// An entry already due when it leaves the exact map is not remembered
+	history.Put("due", now.Add(-time.Minute))
+	history.Put("next", now.Add(time.Minute))
+	if _, ok := history.Get("due"); ok {
+		t.Error("Entry due when dropped should not be seen")
+	}
+}
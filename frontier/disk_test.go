@@ -0,0 +1,167 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDiskQueueResume tests that queued and in-flight requests survive reopening the queue
+func TestDiskQueueResume(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewDiskQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	seedURL, _ := url.Parse("https://example.com/")
+	seed := NewRequest(seedURL)
+	seed.MaxDepth = 2
+	seed.Scope = ScopeSameHost
+	for i := range 5 {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/page%d", i))
+		if err := queue.Push(seed.Child(u), u.String(), 0); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	done, _ := queue.Pop()
+	queue.Done(done)
+	inflight, _ := queue.Pop()
+	if done.Url.Path != "/page0" || inflight.Url.Path != "/page1" {
+		t.Fatalf("Expected FIFO order, got %s then %s", done, inflight)
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	queue, err = NewDiskQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer queue.Close()
+	if queue.Len() != 4 {
+		t.Errorf("Expected 4 queued requests after resuming, got %d", queue.Len())
+	}
+
+	paths := []string{}
+	for {
+		req, err := queue.Pop()
+		if err != nil {
+			t.Fatalf("Pop failed: %v", err)
+		}
+		if req == nil {
+			break
+		}
+		paths = append(paths, req.Url.Path)
+		if req.Depth != 1 || req.Parent.String() != seedURL.String() || req.Seed.String() != seedURL.String() ||
+			req.MaxDepth != 2 || req.Scope != ScopeSameHost {
+			t.Errorf("Provenance of %s was not restored: %+v", req, req)
+		}
+	}
+	if fmt.Sprint(paths) != "[/page1 /page2 /page3 /page4]" {
+		t.Errorf("Unexpected order after resuming: %v", paths)
+	}
+}
+
+// TestDiskQueueCrash tests that requests pushed after the last checkpoint are not lost
+func TestDiskQueueCrash(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewDiskQueue(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	for i := range 3 {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/page%d", i))
+		queue.Push(NewRequest(u), u.String(), 0)
+	}
+	// Simulate a crash by leaving the queue open and reopening the directory
+	crashed, err := NewDiskQueue(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer crashed.Close()
+	if crashed.Len() != 3 {
+		t.Errorf("Expected 3 queued requests after a crash, got %d", crashed.Len())
+	}
+}
+
+// TestDiskHistory tests lookups, growing the table and reopening it
+func TestDiskHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.db")
+	history, err := NewDiskHistory(path)
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+
+	revisitAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	entries := initialHistorySlots
+	for i := range entries {
+		if err := history.Put(fmt.Sprintf("https://example.com/%d", i), revisitAt); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if history.slots <= initialHistorySlots {
+		t.Errorf("Expected table to grow past %d slots", initialHistorySlots)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	history, err = NewDiskHistory(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen history: %v", err)
+	}
+	defer history.Close()
+	if history.Len() != entries {
+		t.Errorf("Expected %d entries, got %d", entries, history.Len())
+	}
+	for _, i := range []int{0, 12345, entries - 1} {
+		got, ok := history.Get(fmt.Sprintf("https://example.com/%d", i))
+		if !ok || !got.Equal(revisitAt) {
+			t.Errorf("Entry %d: expected %v, got %v (found %v)", i, revisitAt, got, ok)
+		}
+	}
+	if _, ok := history.Get("https://example.com/missing"); ok {
+		t.Error("Missing key should not be found")
+	}
+}
+
+// TestFrontierResume tests that a frontier reopened on disk state continues where it stopped
+func TestFrontierResume(t *testing.T) {
+	dir := t.TempDir()
+	open := func(seeds []url.URL) *Frontier {
+		queue, err := NewDiskQueue(filepath.Join(dir, "queue"), 0)
+		if err != nil {
+			t.Fatalf("Failed to open queue: %v", err)
+		}
+		history, err := NewDiskHistory(filepath.Join(dir, "seen.db"))
+		if err != nil {
+			t.Fatalf("Failed to open history: %v", err)
+		}
+		return NewFrontier(seeds, []string{}, WithQueue(queue), WithHistory(history))
+	}
+
+	seedURL, _ := url.Parse("https://example.com/")
+	f := open([]url.URL{*seedURL})
+	seed := <-f.Get()
+	for _, path := range []string{"/a", "/b"} {
+		u, _ := url.Parse("https://example.com" + path)
+		f.Add(seed.Child(u))
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	f = open([]url.URL{*seedURL})
+	defer f.Close()
+	// The seed was never marked done, so it is dispatched again with /a and /b
+	if pending := f.Pending(); pending != 3 {
+		t.Errorf("Expected 3 pending requests after resuming, got %d", pending)
+	}
+	if count := f.Rejected()["already seen"]; count != 1 {
+		t.Errorf("Expected the seed to be rejected as already seen, got %d", count)
+	}
+}
@@ -0,0 +1,217 @@
+package frontier
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	historyMagic = "WCSEEN01"
+	// historyHeaderSize holds the magic, the slot count and the entry count
+	historyHeaderSize = 24
+	// historySlotSize holds a 64-bit URL hash and a revisit time
+	historySlotSize = 16
+	// initialHistorySlots is the size of a new history table
+	initialHistorySlots = 1 << 16
+	// maxHistoryLoad is the fraction of used slots that triggers doubling the table
+	maxHistoryLoad = 0.7
+)
+
+// DiskHistory is a History stored in a file as an open-addressing hash table
+// of 64-bit key hashes and revisit times, so memory use does not depend on
+// the number of URLs seen. Two URLs with the same hash are treated as the
+// same URL, which is vanishingly unlikely below billions of URLs.
+//
+// Expired entries are not evicted but overwritten when their URL is queued
+// again.
+type DiskHistory struct {
+	path  string
+	file  *os.File
+	slots uint64
+	count uint64
+}
+
+// NewDiskHistory opens the history table at path, creating it if needed
+func NewDiskHistory(path string) (*DiskHistory, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	h := &DiskHistory{path: path, file: file}
+
+	header := make([]byte, historyHeaderSize)
+	_, err = file.ReadAt(header, 0)
+	switch {
+	case errors.Is(err, io.EOF):
+		if err := h.create(file, initialHistorySlots); err != nil {
+			file.Close()
+			return nil, err
+		}
+	case err != nil:
+		file.Close()
+		return nil, fmt.Errorf("reading history: %w", err)
+	case string(header[:8]) != historyMagic:
+		file.Close()
+		return nil, fmt.Errorf("reading history: %s is not a history file", path)
+	default:
+		h.slots = binary.LittleEndian.Uint64(header[8:])
+		h.count = binary.LittleEndian.Uint64(header[16:])
+	}
+	return h, nil
+}
+
+// create initializes file as an empty table with the given number of slots
+func (h *DiskHistory) create(file *os.File, slots uint64) error {
+	if err := file.Truncate(historyHeaderSize + int64(slots)*historySlotSize); err != nil {
+		return fmt.Errorf("creating history: %w", err)
+	}
+	h.file, h.slots, h.count = file, slots, 0
+	return h.writeHeader()
+}
+
+func (h *DiskHistory) writeHeader() error {
+	header := make([]byte, historyHeaderSize)
+	copy(header, historyMagic)
+	binary.LittleEndian.PutUint64(header[8:], h.slots)
+	binary.LittleEndian.PutUint64(header[16:], h.count)
+	if _, err := h.file.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
+
+func historyHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	// Zero marks empty slots
+	return max(hash.Sum64(), 1)
+}
+
+// find returns the slot holding hash, or the empty slot where it belongs
+func (h *DiskHistory) find(hash uint64) (slot uint64, revisitAt int64, found bool, err error) {
+	buf := make([]byte, historySlotSize)
+	for i := uint64(0); i < h.slots; i++ {
+		slot = (hash + i) % h.slots
+		if _, err := h.file.ReadAt(buf, historyHeaderSize+int64(slot)*historySlotSize); err != nil {
+			return 0, 0, false, fmt.Errorf("reading history: %w", err)
+		}
+		switch binary.LittleEndian.Uint64(buf) {
+		case 0:
+			return slot, 0, false, nil
+		case hash:
+			return slot, int64(binary.LittleEndian.Uint64(buf[8:])), true, nil
+		}
+	}
+	return 0, 0, false, errors.New("history table is full")
+}
+
+func (h *DiskHistory) Get(key string) (time.Time, bool) {
+	_, revisitAt, found, err := h.find(historyHash(key))
+	if err != nil || !found {
+		return time.Time{}, false
+	}
+	return time.Unix(0, revisitAt), true
+}
+
+func (h *DiskHistory) Put(key string, revisitAt time.Time) error {
+	hash := historyHash(key)
+	slot, _, found, err := h.find(hash)
+	if err != nil {
+		return err
+	}
+	if err := h.writeSlot(slot, hash, revisitAt.UnixNano()); err != nil {
+		return err
+	}
+	if !found {
+		h.count++
+		if float64(h.count) > maxHistoryLoad*float64(h.slots) {
+			return h.grow()
+		}
+		return h.writeHeader()
+	}
+	return nil
+}
+
+func (h *DiskHistory) writeSlot(slot uint64, hash uint64, revisitAt int64) error {
+	buf := make([]byte, historySlotSize)
+	binary.LittleEndian.PutUint64(buf, hash)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(revisitAt))
+	if _, err := h.file.WriteAt(buf, historyHeaderSize+int64(slot)*historySlotSize); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
+
+// grow rehashes every entry into a table twice the size, replacing the file
+// only once it is complete
+func (h *DiskHistory) grow() error {
+	old, oldSlots := h.file, h.slots
+	tmpPath := h.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("growing history: %w", err)
+	}
+	grown := &DiskHistory{path: h.path}
+	if err := grown.create(file, oldSlots*2); err != nil {
+		file.Close()
+		return err
+	}
+
+	// Read the old table in chunks of slots
+	chunk := make([]byte, 4096*historySlotSize)
+	for offset := int64(0); offset < int64(oldSlots)*historySlotSize; offset += int64(len(chunk)) {
+		n, err := old.ReadAt(chunk, historyHeaderSize+offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			file.Close()
+			return fmt.Errorf("growing history: %w", err)
+		}
+		for i := 0; i+historySlotSize <= n; i += historySlotSize {
+			hash := binary.LittleEndian.Uint64(chunk[i:])
+			if hash == 0 {
+				continue
+			}
+			slot, _, _, err := grown.find(hash)
+			if err != nil {
+				file.Close()
+				return err
+			}
+			if err := grown.writeSlot(slot, hash, int64(binary.LittleEndian.Uint64(chunk[i+8:]))); err != nil {
+				file.Close()
+				return err
+			}
+			grown.count++
+		}
+	}
+	if err := grown.writeHeader(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		file.Close()
+		return fmt.Errorf("growing history: %w", err)
+	}
+	old.Close()
+	h.file, h.slots, h.count = file, grown.slots, grown.count
+	return nil
+}
+
+// Evict does nothing, expired entries are overwritten in place
+func (h *DiskHistory) Evict(time.Time) {}
+
+func (h *DiskHistory) Len() int {
+	return int(h.count)
+}
+
+// Close writes the entry count and closes the file
+func (h *DiskHistory) Close() error {
+	err := h.writeHeader()
+	if syncErr := h.file.Sync(); err == nil {
+		err = syncErr
+	}
+	return errors.Join(err, h.file.Close())
+}
@@ -0,0 +1,528 @@
+package frontier
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultQueueBuffer is how many requests a DiskQueue keeps in memory
+	DefaultQueueBuffer = 10000
+	// segmentRecords is the number of requests per queue segment file
+	segmentRecords = 100000
+	// checkpointEvery is the number of pops between two checkpoints
+	checkpointEvery = 1000
+
+	checkpointFile = "checkpoint.json"
+	segmentPrefix  = "segment-"
+	segmentSuffix  = ".jsonl"
+)
+
+// DiskQueue is a Queue persisted to a directory so a crawl can be resumed
+// after it was stopped or crashed. Every pushed request is appended to a
+// segment file, and a bounded buffer of requests read back from the segments
+// is kept in memory and ordered by score, so scoring only reorders requests
+// within that window.
+//
+// A checkpoint records the read position together with the buffered and
+// in-flight requests. It is written every checkpointEvery pops and on Close,
+// and requests popped since the last checkpoint are dispatched again after a
+// crash.
+type DiskQueue struct {
+	dir         string
+	maxBuffered int
+
+	buffer priorityQueue
+	keys   map[string]*queued
+	// inflight holds popped requests until Done is called for them
+	inflight map[*Request]*queued
+	// hosts counts buffered and on-disk requests by host
+	hosts map[string]int
+	seq   uint64
+
+	writer      *os.File
+	writeSeg    int
+	writeCount  int
+	reader      *bufio.Reader
+	readFile    *os.File
+	readSeg     int
+	readOffset  int64
+	onDisk      int
+	popsSinceCp int
+}
+
+// queueRecord is the on-disk form of a queued request
+type queueRecord struct {
+	Url          string    `json:"url"`
+	Depth        int       `json:"depth"`
+	Parent       string    `json:"parent,omitempty"`
+	Seed         string    `json:"seed,omitempty"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+	LastMod      time.Time `json:"lastmod"`
+	ChangeFreq   string    `json:"changefreq,omitempty"`
+	Priority     float64   `json:"priority,omitempty"`
+	MaxDepth     int       `json:"max_depth,omitempty"`
+	Scope        ScopeKind `json:"scope,omitempty"`
+	Key          string    `json:"key"`
+	Score        float64   `json:"score,omitempty"`
+	Seq          uint64    `json:"seq"`
+}
+
+type queueCheckpoint struct {
+	Segment int    `json:"segment"`
+	Offset  int64  `json:"offset"`
+	NextSeq uint64 `json:"next_seq"`
+	// Pending are requests read from the segments but not done yet
+	Pending []queueRecord `json:"pending"`
+}
+
+// NewDiskQueue opens the queue stored in dir, creating it if needed.
+// Requests that were buffered or in flight when the queue was last
+// checkpointed are queued again. maxBuffered defaults to DefaultQueueBuffer.
+func NewDiskQueue(dir string, maxBuffered int) (*DiskQueue, error) {
+	if maxBuffered <= 0 {
+		maxBuffered = DefaultQueueBuffer
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating queue directory: %w", err)
+	}
+	q := &DiskQueue{
+		dir:         dir,
+		maxBuffered: maxBuffered,
+		keys:        make(map[string]*queued),
+		inflight:    make(map[*Request]*queued),
+		readSeg:     1,
+	}
+
+	state, err := loadQueueState(dir)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := state.checkpoint
+	q.readSeg, q.readOffset = checkpoint.Segment, checkpoint.Offset
+	q.writeSeg, q.writeCount = state.writeSeg, state.writeCount
+	q.onDisk, q.hosts = state.onDisk, state.hosts
+	// Every request written since the checkpoint has a smaller sequence number
+	q.seq = checkpoint.NextSeq + uint64(q.onDisk)
+
+	for _, record := range checkpoint.Pending {
+		item, err := record.queued()
+		if err != nil {
+			return nil, err
+		}
+		q.bufferItem(item)
+		q.hosts[queueHost(item.req)]++
+	}
+
+	q.writer, err = os.OpenFile(q.segmentPath(q.writeSeg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening queue segment: %w", err)
+	}
+	if err := q.openReader(); err != nil {
+		q.writer.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *DiskQueue) segmentPath(seg int) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%s%06d%s", segmentPrefix, seg, segmentSuffix))
+}
+
+// segments returns the numbers of the segment files in the queue directory, in order
+func (q *DiskQueue) segments() ([]int, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing queue segments: %w", err)
+	}
+	var segments []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		var seg int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), "%d", &seg); err == nil {
+			segments = append(segments, seg)
+		}
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+// queueState is what a queue directory holds, read without modifying it
+type queueState struct {
+	checkpoint queueCheckpoint
+	writeSeg   int
+	writeCount int
+	// onDisk and hosts count the requests after the read position
+	onDisk int
+	hosts  map[string]int
+	// oldest is the first request after the read position
+	oldest *Request
+}
+
+func loadQueueState(dir string) (*queueState, error) {
+	state := &queueState{
+		checkpoint: queueCheckpoint{Segment: 1},
+		hosts:      make(map[string]int),
+	}
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &state.checkpoint); err != nil {
+			return nil, fmt.Errorf("reading queue checkpoint: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("reading queue checkpoint: %w", err)
+	}
+
+	q := &DiskQueue{dir: dir}
+	segments, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	state.writeSeg = max(state.checkpoint.Segment, 1)
+	if len(segments) > 0 {
+		state.writeSeg = max(state.writeSeg, segments[len(segments)-1])
+	}
+
+	// Requests after the read position are still queued
+	for _, seg := range segments {
+		if seg < state.checkpoint.Segment {
+			continue
+		}
+		offset := int64(0)
+		if seg == state.checkpoint.Segment {
+			offset = state.checkpoint.Offset
+		}
+		count := 0
+		err := scanSegment(q.segmentPath(seg), offset, func(record queueRecord) error {
+			count++
+			item, err := record.queued()
+			if err != nil {
+				return err
+			}
+			if state.oldest == nil {
+				state.oldest = item.req
+			}
+			state.hosts[queueHost(item.req)]++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		state.onDisk += count
+		if seg == state.writeSeg {
+			state.writeCount = count
+		}
+	}
+	return state, nil
+}
+
+// scanSegment calls fn for each complete record in a segment file from offset
+func scanSegment(path string, offset int64, fn func(queueRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening queue segment: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seeking queue segment: %w", err)
+	}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		// A partial last line is a write in progress
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading queue segment: %w", err)
+		}
+		var record queueRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("reading queue segment: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// openReader opens the segment at the read position
+func (q *DiskQueue) openReader() error {
+	if q.readFile != nil {
+		q.readFile.Close()
+	}
+	file, err := os.OpenFile(q.segmentPath(q.readSeg), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening queue segment: %w", err)
+	}
+	if _, err := file.Seek(q.readOffset, io.SeekStart); err != nil {
+		file.Close()
+		return fmt.Errorf("seeking queue segment: %w", err)
+	}
+	q.readFile = file
+	q.reader = bufio.NewReaderSize(file, 64<<10)
+	return nil
+}
+
+func (q *DiskQueue) Push(req *Request, key string, score float64) error {
+	if q.writeCount >= segmentRecords {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(newQueueRecord(req, key, score, q.seq))
+	if err != nil {
+		return err
+	}
+	// One write per record keeps lines whole for the reader
+	if _, err := q.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing queue segment: %w", err)
+	}
+	q.seq++
+	q.writeCount++
+	q.onDisk++
+	q.hosts[queueHost(req)]++
+	return nil
+}
+
+// rotate starts a new segment file for writing
+func (q *DiskQueue) rotate() error {
+	if err := q.writer.Close(); err != nil {
+		return fmt.Errorf("closing queue segment: %w", err)
+	}
+	q.writeSeg++
+	q.writeCount = 0
+	writer, err := os.OpenFile(q.segmentPath(q.writeSeg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening queue segment: %w", err)
+	}
+	q.writer = writer
+	return nil
+}
+
+func (q *DiskQueue) Pop() (*Request, error) {
+	if err := q.fill(); err != nil {
+		return nil, err
+	}
+	if len(q.buffer) == 0 {
+		return nil, nil
+	}
+	item := heap.Pop(&q.buffer).(*queued)
+	if q.keys[item.key] == item {
+		delete(q.keys, item.key)
+	}
+	countOut(q.hosts, queueHost(item.req))
+	q.inflight[item.req] = item
+
+	q.popsSinceCp++
+	if q.popsSinceCp >= checkpointEvery {
+		if err := q.checkpoint(); err != nil {
+			return item.req, err
+		}
+	}
+	return item.req, nil
+}
+
+// fill reads requests from the segments until the buffer is full
+func (q *DiskQueue) fill() error {
+	for len(q.buffer) < q.maxBuffered && q.onDisk > 0 {
+		line, err := q.reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 && q.readSeg < q.writeSeg {
+			q.readSeg++
+			q.readOffset = 0
+			if err := q.openReader(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading queue segment %d: %w", q.readSeg, err)
+		}
+		q.readOffset += int64(len(line))
+		q.onDisk--
+
+		var record queueRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("reading queue segment %d: %w", q.readSeg, err)
+		}
+		item, err := record.queued()
+		if err != nil {
+			return err
+		}
+		q.bufferItem(item)
+	}
+	return nil
+}
+
+func (q *DiskQueue) bufferItem(item *queued) {
+	heap.Push(&q.buffer, item)
+	q.keys[item.key] = item
+}
+
+func (q *DiskQueue) Done(req *Request) {
+	delete(q.inflight, req)
+}
+
+// Rescore only reorders requests in the in-memory buffer
+func (q *DiskQueue) Rescore(key string, score func(*Request) float64) {
+	if item, ok := q.keys[key]; ok {
+		item.score = score(item.req)
+		heap.Fix(&q.buffer, item.index)
+	}
+}
+
+func (q *DiskQueue) Len() int {
+	return len(q.buffer) + q.onDisk
+}
+
+// Stats reports the oldest request among the buffered ones and the next one
+// on disk, requests further back on disk were discovered later
+func (q *DiskQueue) Stats() QueueStats {
+	stats := QueueStats{Len: q.Len(), Hosts: make(map[string]int, len(q.hosts))}
+	for host, count := range q.hosts {
+		stats.Hosts[host] = count
+	}
+	stats.Oldest = q.buffer.oldest()
+	if next := q.peek(); next != nil && (stats.Oldest == nil || next.DiscoveredAt.Before(stats.Oldest.DiscoveredAt)) {
+		stats.Oldest = next
+	}
+	return stats
+}
+
+// peek returns the next request on disk without reading past it, nil if
+// there is none in the current segment
+func (q *DiskQueue) peek() *Request {
+	if q.onDisk == 0 {
+		return nil
+	}
+	data, _ := q.reader.Peek(q.reader.Size())
+	line, _, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil
+	}
+	var record queueRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil
+	}
+	item, err := record.queued()
+	if err != nil {
+		return nil
+	}
+	return item.req
+}
+
+// checkpoint atomically records the read position and the requests read
+// from disk that are not done yet, then removes fully read segments
+func (q *DiskQueue) checkpoint() error {
+	q.popsSinceCp = 0
+	if err := q.writer.Sync(); err != nil {
+		return fmt.Errorf("syncing queue segment: %w", err)
+	}
+
+	checkpoint := queueCheckpoint{
+		Segment: q.readSeg,
+		Offset:  q.readOffset,
+		NextSeq: q.seq,
+		Pending: make([]queueRecord, 0, len(q.inflight)+len(q.buffer)),
+	}
+	for _, item := range q.inflight {
+		checkpoint.Pending = append(checkpoint.Pending, newQueueRecord(item.req, item.key, item.score, item.seq))
+	}
+	for _, item := range q.buffer {
+		checkpoint.Pending = append(checkpoint.Pending, newQueueRecord(item.req, item.key, item.score, item.seq))
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(q.dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing queue checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, checkpointFile)); err != nil {
+		return fmt.Errorf("writing queue checkpoint: %w", err)
+	}
+
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if seg < q.readSeg {
+			os.Remove(q.segmentPath(seg))
+		}
+	}
+	return nil
+}
+
+// Close checkpoints the queue and closes its files
+func (q *DiskQueue) Close() error {
+	err := q.checkpoint()
+	return errors.Join(err, q.writer.Close(), q.readFile.Close())
+}
+
+func newQueueRecord(req *Request, key string, score float64, seq uint64) queueRecord {
+	record := queueRecord{
+		Url:          req.Url.String(),
+		Depth:        req.Depth,
+		DiscoveredAt: req.DiscoveredAt,
+		LastMod:      req.LastMod,
+		ChangeFreq:   req.ChangeFreq,
+		Priority:     req.Priority,
+		MaxDepth:     req.MaxDepth,
+		Scope:        req.Scope,
+		Key:          key,
+		Score:        score,
+		Seq:          seq,
+	}
+	if req.Parent != nil {
+		record.Parent = req.Parent.String()
+	}
+	if req.Seed != nil {
+		record.Seed = req.Seed.String()
+	}
+	return record
+}
+
+func (r queueRecord) queued() (*queued, error) {
+	req := &Request{
+		Depth:        r.Depth,
+		DiscoveredAt: r.DiscoveredAt,
+		LastMod:      r.LastMod,
+		ChangeFreq:   r.ChangeFreq,
+		Priority:     r.Priority,
+		MaxDepth:     r.MaxDepth,
+		Scope:        r.Scope,
+	}
+	var err error
+	if req.Url, err = url.Parse(r.Url); err != nil {
+		return nil, fmt.Errorf("queued request: %w", err)
+	}
+	if r.Parent != "" {
+		if req.Parent, err = url.Parse(r.Parent); err != nil {
+			return nil, fmt.Errorf("queued request: %w", err)
+		}
+	}
+	req.Seed = req.Url
+	if r.Seed != "" {
+		if req.Seed, err = url.Parse(r.Seed); err != nil {
+			return nil, fmt.Errorf("queued request: %w", err)
+		}
+	}
+	return &queued{req: req, key: r.Key, score: r.Score, seq: r.Seq}, nil
+}
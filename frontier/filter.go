@@ -0,0 +1,91 @@
+package frontier
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Filter decides whether a request may be queued. Filters run outside the
+// frontier lock, so they may block, e.g. to fetch robots.txt.
+type Filter interface {
+	// Allow returns false and a short reason when the request must be refused
+	Allow(req *Request) (bool, string)
+}
+
+// Deferrer is implemented by filters that cannot always decide yet, e.g.
+// while robots.txt cannot be fetched. Defer returns how long to wait before
+// req is checked again, 0 when Allow can decide now. A deferred request is
+// neither accepted nor refused, it is added again after the delay and keeps
+// the frontier from terminating until then.
+type Deferrer interface {
+	Defer(req *Request) time.Duration
+}
+
+// check runs the filters on req. It returns the delay of the first filter
+// that cannot decide yet, otherwise whether req is allowed and why not.
+func (f *Frontier) check(req *Request) (time.Duration, bool, string) {
+	for _, filter := range f.filters {
+		if deferrer, ok := filter.(Deferrer); ok {
+			if delay := deferrer.Defer(req); delay > 0 {
+				return delay, false, ""
+			}
+		}
+		if ok, reason := filter.Allow(req); !ok {
+			return 0, false, reason
+		}
+	}
+	return 0, true, ""
+}
+
+// deferRequest adds req again after delay. Once the frontier is terminating
+// req is queued for a persistent frontier to resume instead.
+func (f *Frontier) deferRequest(req *Request, delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	if f.terminating {
+		f.keep(req)
+		return
+	}
+	log.WithFields(log.Fields{
+		"url":   req.Url,
+		"delay": delay,
+	}).Debug("Deferred")
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		f.Add(req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.deferred[timer]; !ok {
+			return
+		}
+		delete(f.deferred, timer)
+		if !f.terminating && f.idle() {
+			log.Debug("No pending or deferred requests left, terminating frontier")
+			f.terminate()
+		}
+	})
+	f.deferred[timer] = req
+}
+
+// keep queues req without dispatching it, so a persistent frontier resumes
+// it, f.mu must be held
+func (f *Frontier) keep(req *Request) {
+	score := 0.0
+	if f.scorer != nil {
+		score = f.scorer.Score(req)
+	}
+	if err := f.queue.Push(req, f.canon.Key(req.Url), score); err != nil {
+		log.WithField("url", req.Url).Errorf("Failed to queue request: %s", err)
+	}
+}
+
+// WithFilter refuses requests that filter does not allow
+func WithFilter(filter Filter) Option {
+	return func(f *Frontier) {
+		f.filters = append(f.filters, filter)
+	}
+}
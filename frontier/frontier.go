@@ -1,69 +1,492 @@
 package frontier
 
 import (
+	"errors"
 	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Fardin-E/web_crawler.git/canonical"
 	log "github.com/sirupsen/logrus"
 )
 
+// evictInterval is how often expired entries are swept from the history
+const evictInterval = time.Minute
+
+// maxRedirectSources bounds the redirecting URLs whose targets are
+// remembered. Beyond it an arbitrary one is forgotten for every new one.
+const maxRedirectSources = 100000
+
+// Frontier is safe for concurrent use. Accepted requests are held in an
+// unbounded priority queue and handed out one at a time through the Get
+// channel, so Add never blocks on slow consumers. Without a Scorer requests
+// are handed out in the order they were added.
+//
+// Every accepted request stays pending until Done is called for it. When the
+// last pending request is done the frontier terminates itself, which is how
+// a crawl detects that it has run out of work, unless revisits are
+// scheduled with WithRecrawl or a Deferrer filter deferred requests.
 type Frontier struct {
-	urls        chan *url.URL
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queue holds accepted requests not yet taken from urls
+	queue  Queue
+	scorer Scorer
+	// inflight holds dispatched requests until they are done
+	inflight map[*Request]struct{}
+	// pending counts accepted requests that are queued or still being crawled
+	pending int
+	// deferred holds requests a filter could not decide on yet, by the
+	// timer adding them again
+	deferred    map[*time.Timer]*Request
+	urls        chan *Request
+	stop        chan struct{}
 	terminating bool
-	history     map[url.URL]time.Time
-	exclude     []string
+	closed      bool
+	// history maps the canonical form of each queued URL to the time it may
+	// be revisited
+	history   History
+	lastEvict time.Time
+	// rejected counts refused requests by reason
+	rejected map[string]int
+	// scope is replaced rather than modified so it can be read without f.mu
+	scope atomic.Pointer[Scope]
+	// maxDepth is the deepest link level accepted, 0 means unlimited
+	maxDepth int
+	// hostBudget is the most requests accepted per host, 0 means unlimited
+	hostBudget int
+	hostPages  map[string]int
+	revisit    RevisitPolicy
+	filters    []Filter
+	// redirects maps the canonical key of each URL that redirected to the
+	// keys of the targets that were followed, so revisits of the URL may
+	// follow them again while they are still seen
+	redirects map[string][]string
+	// traps is nil unless trap detection is enabled
+	traps *trapDetector
+	// recrawl is nil unless adaptive recrawling is enabled
+	recrawl *recrawlSchedule
+	// recrawlPath is where the revisit schedule is kept, empty if only in
+	// memory
+	recrawlPath string
+	canon       *canonical.Canonicalizer
+}
+
+// Option configures optional Frontier behaviour
+type Option func(*Frontier)
+
+// WithMaxDepth refuses requests discovered deeper than depth links from their seed
+func WithMaxDepth(depth int) Option {
+	return func(f *Frontier) {
+		f.maxDepth = depth
+	}
+}
+
+// WithHostBudget refuses requests once pages requests have been accepted for
+// their host. Revisits of a URL count against the budget again.
+func WithHostBudget(pages int) Option {
+	return func(f *Frontier) {
+		f.hostBudget = pages
+	}
+}
+
+// WithCanonicalizer sets how URLs are canonicalized before duplicates are
+// detected, canonical.Default() is used otherwise
+func WithCanonicalizer(canon *canonical.Canonicalizer) Option {
+	return func(f *Frontier) {
+		f.canon = canon
+	}
+}
+
+// WithScope sets which discovered URLs belong to the crawl. Exclude patterns
+// passed to NewFrontier are added to the scope's exclude rules.
+func WithScope(scope Scope) Option {
+	return func(f *Frontier) {
+		f.scope.Store(&scope)
+	}
+}
+
+// WithRevisitPolicy sets how long URLs are considered seen before they may be queued again
+func WithRevisitPolicy(policy RevisitPolicy) Option {
+	return func(f *Frontier) {
+		f.revisit = policy
+	}
 }
 
-func NewFrontier(initialUrls []url.URL, exclude []string) *Frontier {
-	history := make(map[url.URL]time.Time)
+// NewFrontier creates a frontier seeded with initialUrls. Each exclude
+// pattern is parsed with ParseRule, invalid patterns are logged and ignored.
+func NewFrontier(initialUrls []url.URL, exclude []string, opts ...Option) *Frontier {
 	f := &Frontier{
-		urls:    make(chan *url.URL, len(initialUrls)),
-		history: history,
-		exclude: exclude,
+		queue:     newMemoryQueue(),
+		inflight:  make(map[*Request]struct{}),
+		deferred:  make(map[*time.Timer]*Request),
+		urls:      make(chan *Request),
+		stop:      make(chan struct{}),
+		history:   memoryHistory{},
+		lastEvict: time.Now(),
+		rejected:  make(map[string]int),
+		hostPages: make(map[string]int),
+		redirects: make(map[string][]string),
+		revisit:   RevisitPolicy{Default: DefaultRevisitDelay},
+		canon:     canonical.Default(),
+	}
+	f.cond = sync.NewCond(&f.mu)
+	f.scope.Store(&Scope{Kind: ScopeAny})
+	for _, opt := range opts {
+		opt(f)
+	}
+	// A persistent queue may hold requests from a previous run
+	f.pending = f.queue.Len()
+	for _, pattern := range exclude {
+		if err := f.Exclude(pattern); err != nil {
+			log.Errorf("Ignoring exclude pattern: %s", err)
+		}
 	}
 
 	for _, u := range initialUrls {
-		f.Add(&u)
+		f.Add(NewRequest(&u))
+	}
+	if f.recrawl != nil && f.recrawlPath != "" {
+		if err := f.loadRecrawl(); err != nil {
+			log.Errorf("Starting without the saved revisit schedule: %s", err)
+		}
+	}
+	go f.dispatch()
+	if f.recrawl != nil {
+		go f.recrawlLoop()
 	}
 	return f
 }
 
-func (f *Frontier) Add(url *url.URL) bool {
-	if f.terminating {
+func (f *Frontier) Add(req *Request) bool {
+	if req == nil || req.Url == nil {
+		return false
+	}
+	maxDepth := f.maxDepth
+	if req.MaxDepth != 0 {
+		maxDepth = req.MaxDepth
+	}
+	if maxDepth > 0 && req.Depth > maxDepth {
+		f.reject(req, "beyond max depth")
 		return false
 	}
-	if f.Seen(url) {
-		log.WithFields(log.Fields{
-			"url": url,
-		}).Info("Already seen")
+	if ok, reason := f.scope.Load().Check(req); !ok {
+		f.reject(req, reason)
 		return false
 	}
-	for _, pattern := range f.exclude {
-		if pattern == url.Host {
-			log.WithFields(log.Fields{
-				"url": url,
-			}).Info("Excluded")
+	if delay, ok, reason := f.check(req); delay > 0 {
+		f.deferRequest(req, delay)
+		return false
+	} else if !ok {
+		f.reject(req, reason)
+		return false
+	}
+	observer, observing := f.scorer.(Observer)
+	if observing {
+		observer.Observe(req)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return false
+	}
+	key := f.canon.Key(req.Url)
+	if f.seen(req.Url) {
+		// What the scorer learned from the duplicate may change the priority
+		// of the request still waiting in the queue
+		if observing {
+			f.queue.Rescore(key, f.scorer.Score)
+		}
+		f.rejectLocked(req, "already seen")
+		return false
+	}
+	if f.traps != nil {
+		if ok, reason := f.traps.check(req, key); !ok {
+			f.rejectLocked(req, reason)
 			return false
 		}
 	}
-	f.history[*url] = time.Now()
-	f.urls <- url
+	host := f.canon.Canonicalize(req.Url).Host
+	if f.hostBudget > 0 && f.hostPages[host] >= f.hostBudget {
+		f.rejectLocked(req, "host budget exhausted")
+		return false
+	}
+	score := 0.0
+	if f.scorer != nil {
+		score = f.scorer.Score(req)
+	}
+	if err := f.queue.Push(req, key, score); err != nil {
+		log.WithField("url", req.Url).Errorf("Failed to queue request: %s", err)
+		return false
+	}
+	if err := f.history.Put(key, time.Now().Add(f.revisit.DelayFor(req))); err != nil {
+		log.WithField("url", req.Url).Errorf("Failed to record request: %s", err)
+	}
+	f.hostPages[host]++
+	f.evictExpired()
+	// Requests discovered while the crawl winds down are kept in the queue,
+	// so a persistent frontier resumes them, but never dispatched
+	if f.terminating {
+		return false
+	}
+	f.pending++
+	f.cond.Signal()
 
 	return true
 }
 
-func (f *Frontier) Get() chan *url.URL {
+// Redirect reports whether a redirect of req to target may be followed, and
+// the reason if not. Target must be in req's scope, pass the filters and not
+// be seen yet, unless it is req's own URL in another form or req's URL
+// redirected to it before. A followed target is recorded as seen, so it is
+// not queued and fetched again.
+func (f *Frontier) Redirect(req *Request, target *url.URL) (bool, string) {
+	hop := *req
+	hop.Url = target
+	if ok, reason := f.scope.Load().Check(&hop); !ok {
+		f.reject(&hop, reason)
+		return false, reason
+	}
+	// A target that cannot be decided on yet is fetched later on its own
+	if delay, ok, reason := f.check(&hop); delay > 0 {
+		f.deferRequest(&hop, delay)
+		return false, "deferred"
+	} else if !ok {
+		f.reject(&hop, reason)
+		return false, reason
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return false, "frontier closed"
+	}
+	key := f.canon.Key(target)
+	source := f.canon.Key(req.Url)
+	if key == source {
+		return true, ""
+	}
+	// A revisit of a redirecting page finds its target still seen
+	revisit := slices.Contains(f.redirects[source], key)
+	if !revisit && f.seen(target) {
+		f.rejectLocked(&hop, "already seen")
+		return false, "already seen"
+	}
+	if err := f.history.Put(key, time.Now().Add(f.revisit.DelayFor(&hop))); err != nil {
+		log.WithField("url", target).Errorf("Failed to record request: %s", err)
+	}
+	if !revisit {
+		if _, ok := f.redirects[source]; !ok {
+			forgetOne(f.redirects, maxRedirectSources)
+		}
+		f.redirects[source] = append(f.redirects[source], key)
+	}
+	return true, ""
+}
+
+// Exclude adds an exclude rule, see ParseRule for the pattern syntax
+func (f *Frontier) Exclude(pattern string) error {
+	rule, err := ParseRule(pattern)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	scope := *f.scope.Load()
+	scope.Exclude = append(append([]*Rule{}, scope.Exclude...), rule)
+	f.scope.Store(&scope)
+	return nil
+}
+
+// Rejected returns how many requests were refused, by reason
+func (f *Frontier) Rejected() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rejected := make(map[string]int, len(f.rejected))
+	for reason, count := range f.rejected {
+		rejected[reason] = count
+	}
+	return rejected
+}
+
+func (f *Frontier) reject(req *Request, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rejectLocked(req, reason)
+}
+
+// rejectLocked counts and logs a refused request, f.mu must be held
+func (f *Frontier) rejectLocked(req *Request, reason string) {
+	f.rejected[reason]++
+	log.WithFields(log.Fields{
+		"url":    req.Url,
+		"reason": reason,
+	}).Debug("Rejected")
+}
+
+// Done marks a request taken from Get as fully handled. Any URLs discovered
+// while handling it must be added before calling Done.
+func (f *Frontier) Done(req *Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queue.Done(req)
+	delete(f.inflight, req)
+	if f.terminating || f.pending == 0 {
+		return
+	}
+	f.pending--
+	if f.pending == 0 {
+		if f.recrawl != nil && len(f.recrawl.entries) > 0 {
+			log.Debug("No pending requests left, waiting for scheduled revisits")
+			return
+		}
+		if len(f.deferred) > 0 {
+			log.Debug("No pending requests left, waiting for deferred requests")
+			return
+		}
+		log.Debug("No pending requests left, terminating frontier")
+		f.terminate()
+	}
+}
+
+// idle reports whether the frontier has no work left that could ever be
+// dispatched, f.mu must be held
+func (f *Frontier) idle() bool {
+	return f.pending == 0 && len(f.deferred) == 0 && (f.recrawl == nil || len(f.recrawl.entries) == 0)
+}
+
+// Pending returns the number of accepted requests that are not done yet
+func (f *Frontier) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pending
+}
+
+// Get returns the channel requests are dispatched on, it is closed once the
+// frontier is terminated
+func (f *Frontier) Get() <-chan *Request {
 	return f.urls
 }
 
+// Terminate stops dispatching and closes the Get channel. Queued requests
+// are left in the queue, and requests added afterwards are still queued but
+// Add returns false for them, so a persistent queue can resume them.
+// Calling it more than once is safe.
 func (f *Frontier) Terminate() {
-	close(f.urls)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terminate()
+}
+
+// terminate implements Terminate, f.mu must be held
+func (f *Frontier) terminate() {
+	if f.terminating {
+		return
+	}
 	f.terminating = true
+	f.pending = 0
+	// Deferred requests whose timer has not fired yet are kept for a
+	// persistent frontier, the others are being added already
+	for timer, req := range f.deferred {
+		if timer.Stop() {
+			f.keep(req)
+		}
+	}
+	clear(f.deferred)
+	close(f.stop)
+	f.cond.Broadcast()
+}
+
+// dispatch moves queued requests onto the urls channel until the frontier is
+// terminated
+func (f *Frontier) dispatch() {
+	defer close(f.urls)
+	for {
+		f.mu.Lock()
+		for f.queue.Len() == 0 && !f.terminating {
+			f.cond.Wait()
+		}
+		if f.terminating {
+			f.mu.Unlock()
+			return
+		}
+		req, err := f.queue.Pop()
+		if err != nil {
+			log.Errorf("Failed to read the frontier queue, terminating: %s", err)
+			f.terminate()
+		}
+		if req != nil {
+			f.inflight[req] = struct{}{}
+		}
+		f.mu.Unlock()
+		if req == nil {
+			continue
+		}
+
+		select {
+		case f.urls <- req:
+		case <-f.stop:
+			return
+		}
+	}
 }
 
 func (f *Frontier) Seen(url *url.URL) bool {
-	if lastFetch, ok := f.history[*url]; ok {
-		return time.Since(lastFetch) < 2*time.Hour
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seen(url)
+}
+
+// MarkSeen records url as visited without queueing it, e.g. because its
+// content was already fetched under another URL
+func (f *Frontier) MarkSeen(url *url.URL) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.history.Put(f.canon.Key(url), time.Now().Add(f.revisit.DelayFor(NewRequest(url)))); err != nil {
+		log.WithField("url", url).Errorf("Failed to record request: %s", err)
+	}
+}
+
+// seen reports whether url is still within its revisit delay, f.mu must be held
+func (f *Frontier) seen(url *url.URL) bool {
+	if revisitAt, ok := f.history.Get(f.canon.Key(url)); ok {
+		return time.Now().Before(revisitAt)
 	}
 	return false
 }
+
+// evictExpired drops history entries whose revisit delay has passed so the
+// history does not grow without bound on long-running crawls, f.mu must be held
+func (f *Frontier) evictExpired() {
+	now := time.Now()
+	if now.Sub(f.lastEvict) < evictInterval {
+		return
+	}
+	f.lastEvict = now
+	f.history.Evict(now)
+}
+
+// Close terminates the frontier and closes its queue and history, which
+// flushes persistent ones to disk, and saves the revisit schedule if it is
+// kept in a file. The frontier cannot be used afterwards.
+func (f *Frontier) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terminate()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	var saveErr error
+	if f.recrawl != nil && f.recrawlPath != "" {
+		saveErr = f.saveRecrawl()
+	}
+	return errors.Join(saveErr, f.queue.Close(), f.history.Close())
+}
@@ -1,7 +1,9 @@
 package frontier
 
 import (
+	"fmt"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 )
@@ -14,7 +16,7 @@ func TestFrontierAdd(t *testing.T) {
 	testURL, _ := url.Parse("https://example.com")
 
 	// First add should succeed
-	added := f.Add(testURL)
+	added := f.Add(NewRequest(testURL))
 	if !added {
 		t.Error("Expected URL to be added successfully")
 	}
@@ -38,7 +40,7 @@ func TestFrontierDeduplication(t *testing.T) {
 	testURL, _ := url.Parse("https://example.com/page1")
 
 	// First add should succeed
-	added1 := f.Add(testURL)
+	added1 := f.Add(NewRequest(testURL))
 	if !added1 {
 		t.Error("First add should succeed")
 	}
@@ -47,7 +49,7 @@ func TestFrontierDeduplication(t *testing.T) {
 	<-f.Get()
 
 	// Second add of same URL should fail (within revisit delay)
-	added2 := f.Add(testURL)
+	added2 := f.Add(NewRequest(testURL))
 	if added2 {
 		t.Error("Duplicate URL should not be added")
 	}
@@ -73,7 +75,7 @@ func TestFrontierExcludePatterns(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			testURL, _ := url.Parse(tt.urlStr)
-			added := f.Add(testURL)
+			added := f.Add(NewRequest(testURL))
 
 			if added != tt.shouldBeAdded {
 				t.Errorf("URL %s: expected added=%v, got added=%v",
@@ -129,7 +131,7 @@ func TestFrontierTerminate(t *testing.T) {
 
 	// Try to add URL after termination
 	testURL, _ := url.Parse("https://example.com")
-	added := f.Add(testURL)
+	added := f.Add(NewRequest(testURL))
 
 	if added {
 		t.Error("Should not be able to add URL after termination")
@@ -155,11 +157,11 @@ func TestFrontierRevisitDelay(t *testing.T) {
 	testURL, _ := url.Parse("https://example.com/page")
 
 	// Add URL
-	f.Add(testURL)
+	f.Add(NewRequest(testURL))
 	<-f.Get() // Consume it
 
 	// Immediately try to add again (should fail)
-	added := f.Add(testURL)
+	added := f.Add(NewRequest(testURL))
 	if added {
 		t.Error("URL should not be re-added within revisit delay")
 	}
@@ -182,7 +184,7 @@ func TestFrontierConcurrency(t *testing.T) {
 		go func(id int) {
 			for j := 0; j < 10; j++ {
 				testURL, _ := url.Parse("https://example.com/page" + string(rune(id*10+j)))
-				f.Add(testURL)
+				f.Add(NewRequest(testURL))
 			}
 			done <- true
 		}(i)
@@ -222,7 +224,7 @@ func TestFrontierEmptyFrontier(t *testing.T) {
 
 	// Add a URL
 	testURL, _ := url.Parse("https://example.com")
-	added := f.Add(testURL)
+	added := f.Add(NewRequest(testURL))
 
 	if !added {
 		t.Error("Should be able to add URL to empty frontier")
@@ -238,3 +240,280 @@ func TestFrontierEmptyFrontier(t *testing.T) {
 		t.Fatal("Timeout retrieving URL from frontier")
 	}
 }
+
+// TestFrontierMaxDepth tests that requests beyond the max depth are refused
+func TestFrontierMaxDepth(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com")
+	f := NewFrontier([]url.URL{*seedURL}, []string{}, WithMaxDepth(1))
+
+	seed := <-f.Get()
+	if seed.Depth != 0 {
+		t.Errorf("Expected seed depth 0, got %d", seed.Depth)
+	}
+
+	childURL, _ := url.Parse("https://example.com/child")
+	child := seed.Child(childURL)
+	if child.Depth != 1 || child.Parent.String() != seedURL.String() {
+		t.Errorf("Unexpected child provenance: depth=%d parent=%v", child.Depth, child.Parent)
+	}
+
+	grandchildURL, _ := url.Parse("https://example.com/child/grandchild")
+	if f.Add(child.Child(grandchildURL)) {
+		t.Error("Request beyond max depth should not be added")
+	}
+	if f.Seen(grandchildURL) {
+		t.Error("Refused request should not be marked as seen")
+	}
+}
+
+// TestFrontierPerSeedMaxDepth tests that a seed's own max depth overrides the frontier's
+func TestFrontierPerSeedMaxDepth(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{}, WithMaxDepth(1))
+	defer f.Terminate()
+
+	shallowURL, _ := url.Parse("https://shallow.com/")
+	deepURL, _ := url.Parse("https://deep.com/")
+	shallow := NewRequest(shallowURL)
+	deep := NewRequest(deepURL)
+	deep.MaxDepth = 3
+
+	for _, seed := range []*Request{shallow, deep} {
+		req := seed
+		for depth := 1; depth <= 3; depth++ {
+			u, _ := url.Parse(fmt.Sprintf("%s%d", seed.Url, depth))
+			req = req.Child(u)
+			expected := depth <= 1 || seed == deep
+			if got := f.Add(req); got != expected {
+				t.Errorf("Add %s at depth %d: expected %v, got %v", req, depth, expected, got)
+			}
+		}
+	}
+}
+
+// TestFrontierRedirect tests which redirect targets may be followed and that followed ones count as seen
+func TestFrontierRedirect(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/")
+	f := NewFrontier([]url.URL{*seedURL}, []string{"/private"}, WithScope(Scope{Kind: ScopeSameHost}))
+	defer f.Terminate()
+
+	seed := <-f.Get()
+	oldURL, _ := url.Parse("https://example.com/old")
+	req := seed.Child(oldURL)
+
+	tests := []struct {
+		target   string
+		expected bool
+		reason   string
+	}{
+		{"https://example.com/new", true, ""},
+		// A revisit of /old follows its redirect again
+		{"https://example.com/new", true, ""},
+		{"https://example.com/", false, "already seen"},
+		{"https://example.com/old?utm_source=feed", true, ""},
+		{"https://other.com/", false, "outside same-host scope"},
+		{"https://example.com/private", false, "excluded by /private"},
+	}
+	for _, tt := range tests {
+		target, _ := url.Parse(tt.target)
+		ok, reason := f.Redirect(req, target)
+		if ok != tt.expected || (!ok && reason != tt.reason) {
+			t.Errorf("Redirect to %s: expected %v %q, got %v %q", tt.target, tt.expected, tt.reason, ok, reason)
+		}
+	}
+
+	newURL, _ := url.Parse("https://example.com/new")
+	if f.Add(seed.Child(newURL)) {
+		t.Error("A followed redirect target should not be queued again")
+	}
+	otherURL, _ := url.Parse("https://example.com/other")
+	if ok, reason := f.Redirect(seed.Child(otherURL), newURL); ok || reason != "already seen" {
+		t.Errorf("Redirect of another URL to a seen target: expected refused as already seen, got %v %q", ok, reason)
+	}
+}
+
+// TestFrontierAddDoesNotBlock tests that Add never blocks when nobody is consuming
+func TestFrontierAddDoesNotBlock(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{})
+
+	added := make(chan int)
+	go func() {
+		count := 0
+		for i := 0; i < 1000; i++ {
+			testURL, _ := url.Parse(fmt.Sprintf("https://example.com/page%d", i))
+			if f.Add(NewRequest(testURL)) {
+				count++
+			}
+		}
+		added <- count
+	}()
+
+	select {
+	case count := <-added:
+		if count != 1000 {
+			t.Errorf("Expected 1000 URLs to be added, got %d", count)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Add blocked without a consumer")
+	}
+
+	// URLs are dispatched in the order they were added
+	first := <-f.Get()
+	if first.String() != "https://example.com/page0" {
+		t.Errorf("Expected first URL page0, got %s", first)
+	}
+}
+
+// TestFrontierAddDuringTerminate tests that Add and Terminate can race safely
+func TestFrontierAddDuringTerminate(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				testURL, _ := url.Parse(fmt.Sprintf("https://example.com/%d/%d", id, j))
+				f.Add(NewRequest(testURL))
+			}
+		}(i)
+	}
+
+	f.Terminate()
+	f.Terminate()
+	wg.Wait()
+
+	for range f.Get() {
+		// Drain until the dispatcher closes the channel
+	}
+}
+
+// TestFrontierTerminatesWhenDone tests that the frontier closes once every request is done
+func TestFrontierTerminatesWhenDone(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com")
+	f := NewFrontier([]url.URL{*seedURL}, []string{})
+
+	seed := <-f.Get()
+
+	// A link discovered while handling the seed keeps the frontier alive
+	childURL, _ := url.Parse("https://example.com/child")
+	f.Add(seed.Child(childURL))
+	f.Done(seed)
+	if f.Pending() != 1 {
+		t.Fatalf("Expected 1 pending request, got %d", f.Pending())
+	}
+
+	child := <-f.Get()
+	f.Done(child)
+
+	select {
+	case _, ok := <-f.Get():
+		if ok {
+			t.Error("Channel should be closed once all requests are done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Frontier did not terminate after its last request was done")
+	}
+}
+
+// deferOnce defers every URL once by delay
+type deferOnce struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	deferred map[string]bool
+}
+
+func (d *deferOnce) Defer(req *Request) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.deferred[req.Url.String()] {
+		return 0
+	}
+	d.deferred[req.Url.String()] = true
+	return d.delay
+}
+
+func (d *deferOnce) Allow(req *Request) (bool, string) {
+	return true, ""
+}
+
+// TestFrontierDeferredRequests tests that deferred requests keep the frontier alive and are kept on terminate
+func TestFrontierDeferredRequests(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/")
+	f := NewFrontier([]url.URL{*seedURL}, []string{},
+		WithFilter(&deferOnce{delay: 50 * time.Millisecond, deferred: map[string]bool{}}))
+	if stats := f.Stats(); stats.Deferred != 1 || f.Pending() != 0 {
+		t.Fatalf("Expected the seed to be deferred, got %d deferred and %d pending", stats.Deferred, f.Pending())
+	}
+	select {
+	case req := <-f.Get():
+		if req.Url.String() != seedURL.String() {
+			t.Errorf("Expected the seed, got %s", req)
+		}
+		f.Done(req)
+	case <-time.After(time.Second):
+		t.Fatal("Deferred seed was not added again")
+	}
+	select {
+	case _, ok := <-f.Get():
+		if ok {
+			t.Error("Channel should be closed once the deferred request is done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Frontier did not terminate after its deferred request was done")
+	}
+
+	// A crawl stopping before the delay keeps the request in the queue
+	f = NewFrontier([]url.URL{*seedURL}, []string{},
+		WithFilter(&deferOnce{delay: time.Hour, deferred: map[string]bool{}}))
+	f.Terminate()
+	if stats := f.Stats(); stats.Deferred != 0 || stats.Queued != 1 {
+		t.Errorf("Expected the deferred seed to be queued on terminate, got %d deferred and %d queued", stats.Deferred, stats.Queued)
+	}
+}
+
+// TestFrontierCanonicalDeduplication tests that different spellings of a URL are duplicates
+func TestFrontierCanonicalDeduplication(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{})
+
+	original, _ := url.Parse("https://example.com/list?page=2&sort=asc")
+	if !f.Add(NewRequest(original)) {
+		t.Fatal("First add should succeed")
+	}
+
+	for _, variant := range []string{
+		"HTTPS://EXAMPLE.com:443/list?sort=asc&page=2",
+		"https://example.com/list?page=2&sort=asc&utm_source=newsletter#top",
+		"https://example.com/other/../list?page=2&sort=asc",
+	} {
+		u, _ := url.Parse(variant)
+		if f.Add(NewRequest(u)) {
+			t.Errorf("Variant %s should be detected as a duplicate", variant)
+		}
+	}
+
+	nextPage, _ := url.Parse("https://example.com/list?page=3&sort=asc")
+	if !f.Add(NewRequest(nextPage)) {
+		t.Error("A different page of the listing should be added")
+	}
+}
+
+// TestFrontierHostBudget tests that each host only gets its budget of requests
+func TestFrontierHostBudget(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{}, WithHostBudget(2))
+
+	for i, expected := range []bool{true, true, false} {
+		testURL, _ := url.Parse(fmt.Sprintf("https://EXAMPLE.com:443/page%d", i))
+		if got := f.Add(NewRequest(testURL)); got != expected {
+			t.Errorf("Add page%d: expected %v, got %v", i, expected, got)
+		}
+	}
+
+	otherURL, _ := url.Parse("https://other.com/")
+	if !f.Add(NewRequest(otherURL)) {
+		t.Error("Other hosts should have their own budget")
+	}
+	if count := f.Rejected()["host budget exhausted"]; count != 1 {
+		t.Errorf("Expected 1 rejection for the host budget, got %d", count)
+	}
+}
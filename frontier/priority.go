@@ -0,0 +1,264 @@
+package frontier
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fardin-E/web_crawler.git/canonical"
+)
+
+// Scorer decides the dispatch order of queued requests, higher scores are
+// dispatched first. Requests with equal scores are dispatched in the order
+// they were added, so a frontier without a scorer is FIFO.
+type Scorer interface {
+	Score(req *Request) float64
+}
+
+// Observer is implemented by scorers that learn from every request offered to
+// the frontier, including duplicates of queued requests. Queued requests are
+// rescored whenever a duplicate of them is observed.
+type Observer interface {
+	Observe(req *Request)
+}
+
+// WithScorer orders queued requests by score instead of first in, first out
+func WithScorer(scorer Scorer) Option {
+	return func(f *Frontier) {
+		f.scorer = scorer
+	}
+}
+
+// DepthScorer favours requests close to their seed, scoring 1 for seeds and
+// 1/(depth+1) below them
+type DepthScorer struct{}
+
+func (DepthScorer) Score(req *Request) float64 {
+	return 1 / float64(req.Depth+1)
+}
+
+// SitemapPriorityScorer scores requests by their sitemap <priority>, between
+// 0 and 1. Requests that did not come from a sitemap score 0.
+type SitemapPriorityScorer struct{}
+
+func (SitemapPriorityScorer) Score(req *Request) float64 {
+	return req.Priority
+}
+
+// FreshnessScorer favours recently modified pages by their sitemap
+// <lastmod>. The score halves every HalfLife, DefaultFreshnessHalfLife if
+// zero, and is 0 when the modification time is unknown.
+type FreshnessScorer struct {
+	HalfLife time.Duration
+}
+
+// DefaultFreshnessHalfLife is the FreshnessScorer half-life when none is set
+const DefaultFreshnessHalfLife = 30 * 24 * time.Hour
+
+func (s FreshnessScorer) Score(req *Request) float64 {
+	if req.LastMod.IsZero() {
+		return 0
+	}
+	halfLife := s.HalfLife
+	if halfLife <= 0 {
+		halfLife = DefaultFreshnessHalfLife
+	}
+	age := max(time.Since(req.LastMod), 0)
+	return math.Exp2(-float64(age) / float64(halfLife))
+}
+
+// InlinkScorer favours URLs that many pages link to. It counts every time a
+// URL is offered to the frontier, so the score of a queued request grows as
+// more links to it are discovered. Counts are kept for the whole crawl.
+type InlinkScorer struct {
+	// Canonicalizer identifies duplicate URLs, canonical.Default() if nil
+	Canonicalizer *canonical.Canonicalizer
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *InlinkScorer) key(req *Request) string {
+	if s.Canonicalizer == nil {
+		return canonical.Default().Key(req.Url)
+	}
+	return s.Canonicalizer.Key(req.Url)
+}
+
+func (s *InlinkScorer) Observe(req *Request) {
+	if req.Parent == nil {
+		return
+	}
+	key := s.key(req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string]int)
+	}
+	s.counts[key]++
+}
+
+// Score returns log2(inlinks+1) so a handful of links matters more than the
+// difference between hundreds
+func (s *InlinkScorer) Score(req *Request) float64 {
+	key := s.key(req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return math.Log2(float64(s.counts[key] + 1))
+}
+
+// PatternWeight assigns Weight to URLs matching Rule
+type PatternWeight struct {
+	Rule   *Rule
+	Weight float64
+}
+
+// ParsePatternWeight parses "pattern=weight", where pattern uses the ParseRule
+// syntax, for example "/docs/*=5" or "re:/archive/=-2"
+func ParsePatternWeight(spec string) (PatternWeight, error) {
+	i := strings.LastIndex(spec, "=")
+	if i < 0 {
+		return PatternWeight{}, fmt.Errorf("pattern weight %q: expected pattern=weight", spec)
+	}
+	weight, err := strconv.ParseFloat(spec[i+1:], 64)
+	if err != nil {
+		return PatternWeight{}, fmt.Errorf("pattern weight %q: %w", spec, err)
+	}
+	rule, err := ParseRule(spec[:i])
+	if err != nil {
+		return PatternWeight{}, err
+	}
+	return PatternWeight{Rule: rule, Weight: weight}, nil
+}
+
+// PatternScorer scores requests by the weight of the first matching pattern,
+// 0 if none matches
+type PatternScorer struct {
+	Patterns []PatternWeight
+}
+
+func (s PatternScorer) Score(req *Request) float64 {
+	for _, pattern := range s.Patterns {
+		if pattern.Rule.Matches(req.Url) {
+			return pattern.Weight
+		}
+	}
+	return 0
+}
+
+// WeightedScorer is a Scorer and the weight of its score in a CompositeScorer
+type WeightedScorer struct {
+	Scorer Scorer
+	Weight float64
+}
+
+// CompositeScorer sums the weighted scores of several scorers
+type CompositeScorer []WeightedScorer
+
+func (c CompositeScorer) Score(req *Request) float64 {
+	score := 0.0
+	for _, weighted := range c {
+		score += weighted.Weight * weighted.Scorer.Score(req)
+	}
+	return score
+}
+
+func (c CompositeScorer) Observe(req *Request) {
+	for _, weighted := range c {
+		if observer, ok := weighted.Scorer.(Observer); ok {
+			observer.Observe(req)
+		}
+	}
+}
+
+// NewScorer returns the built-in scorer called name: depth, sitemap,
+// freshness or inlinks
+func NewScorer(name string, canon *canonical.Canonicalizer) (Scorer, error) {
+	switch strings.ToLower(name) {
+	case "depth":
+		return DepthScorer{}, nil
+	case "sitemap":
+		return SitemapPriorityScorer{}, nil
+	case "freshness":
+		return FreshnessScorer{}, nil
+	case "inlinks":
+		return &InlinkScorer{Canonicalizer: canon}, nil
+	}
+	return nil, fmt.Errorf("unknown scorer %q, expected one of depth, sitemap, freshness, inlinks", name)
+}
+
+// ParseWeightedScorer parses "name=weight" for a built-in scorer, the weight
+// defaults to 1 when omitted
+func ParseWeightedScorer(spec string, canon *canonical.Canonicalizer) (WeightedScorer, error) {
+	name, weightStr, hasWeight := strings.Cut(spec, "=")
+	weight := 1.0
+	if hasWeight {
+		var err error
+		if weight, err = strconv.ParseFloat(weightStr, 64); err != nil {
+			return WeightedScorer{}, fmt.Errorf("scorer %q: %w", spec, err)
+		}
+	}
+	scorer, err := NewScorer(name, canon)
+	if err != nil {
+		return WeightedScorer{}, err
+	}
+	return WeightedScorer{Scorer: scorer, Weight: weight}, nil
+}
+
+// queued is a request waiting in the frontier's priority queue
+type queued struct {
+	req   *Request
+	key   string
+	score float64
+	// seq breaks ties so equal scores keep their insertion order
+	seq   uint64
+	index int
+}
+
+// priorityQueue implements heap.Interface, highest score first
+type priorityQueue []*queued
+
+func (q priorityQueue) Len() int { return len(q) }
+
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].score != q[j].score {
+		return q[i].score > q[j].score
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q priorityQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *priorityQueue) Push(x any) {
+	item := x.(*queued)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *priorityQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
+
+// oldest returns the queued request discovered first, nil if q is empty
+func (q priorityQueue) oldest() *Request {
+	var oldest *Request
+	for _, item := range q {
+		if oldest == nil || item.req.DiscoveredAt.Before(oldest.DiscoveredAt) {
+			oldest = item.req
+		}
+	}
+	return oldest
+}
@@ -0,0 +1,109 @@
+package frontier
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestFrontierScorerOrder tests that higher scoring requests are dispatched first
+func TestFrontierScorerOrder(t *testing.T) {
+	docs, _ := ParsePatternWeight("/docs=5")
+	archive, _ := ParsePatternWeight("/archive=-1")
+	scorer := PatternScorer{Patterns: []PatternWeight{docs, archive}}
+
+	seeds := []url.URL{}
+	for _, s := range []string{"https://example.com/archive/1", "https://example.com/a", "https://example.com/docs/x", "https://example.com/b"} {
+		u, _ := url.Parse(s)
+		seeds = append(seeds, *u)
+	}
+	f := NewFrontier(seeds, []string{}, WithScorer(scorer))
+	defer f.Terminate()
+
+	expected := []string{"/docs/x", "/a", "/b", "/archive/1"}
+	for _, path := range expected {
+		req := <-f.Get()
+		if req.Url.Path != path {
+			t.Errorf("Expected %s, got %s", path, req.Url.Path)
+		}
+	}
+}
+
+// TestFrontierInlinkRescoring tests that discovering more links to a queued URL raises its priority
+func TestFrontierInlinkRescoring(t *testing.T) {
+	seeds := []url.URL{}
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		u, _ := url.Parse("https://example.com/" + s)
+		seeds = append(seeds, *u)
+	}
+	f := NewFrontier(seeds, []string{}, WithScorer(&InlinkScorer{}))
+	defer f.Terminate()
+
+	parent := <-f.Get()
+	popular, _ := url.Parse("https://example.com/e")
+	for range 3 {
+		f.Add(parent.Child(popular))
+	}
+
+	order := map[string]int{}
+	for i := range 4 {
+		order[(<-f.Get()).Url.Path] = i
+	}
+	if order["/e"] > order["/c"] || order["/e"] > order["/d"] {
+		t.Errorf("Expected /e to be dispatched before /c and /d, got %v", order)
+	}
+}
+
+// TestScorers tests the built-in scorers and their weighted combination
+func TestScorers(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/")
+	seed := NewRequest(seedURL)
+	childURL, _ := url.Parse("https://example.com/child")
+	child := seed.Child(childURL)
+	child.Priority = 0.8
+	child.LastMod = time.Now()
+
+	if got := (DepthScorer{}).Score(seed); got != 1 {
+		t.Errorf("Expected depth score 1 for a seed, got %v", got)
+	}
+	if got := (DepthScorer{}).Score(child); got != 0.5 {
+		t.Errorf("Expected depth score 0.5 for a child, got %v", got)
+	}
+	if got := (SitemapPriorityScorer{}).Score(child); got != 0.8 {
+		t.Errorf("Expected sitemap priority 0.8, got %v", got)
+	}
+	if got := (FreshnessScorer{}).Score(child); got < 0.99 {
+		t.Errorf("Expected a fresh page to score close to 1, got %v", got)
+	}
+	child.LastMod = time.Now().Add(-DefaultFreshnessHalfLife)
+	if got := (FreshnessScorer{}).Score(child); got < 0.49 || got > 0.51 {
+		t.Errorf("Expected a page one half-life old to score 0.5, got %v", got)
+	}
+	if got := (FreshnessScorer{}).Score(seed); got != 0 {
+		t.Errorf("Expected unknown modification time to score 0, got %v", got)
+	}
+
+	depth, err := ParseWeightedScorer("depth=2", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sitemap, err := ParseWeightedScorer("sitemap", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	composite := CompositeScorer{depth, sitemap}
+	if got := composite.Score(child); got != 2*0.5+0.8 {
+		t.Errorf("Expected composite score 1.8, got %v", got)
+	}
+
+	for _, spec := range []string{"pagerank=1", "depth=high"} {
+		if _, err := ParseWeightedScorer(spec, nil); err == nil {
+			t.Errorf("Expected error for scorer %q", spec)
+		}
+	}
+	for _, spec := range []string{"/docs", "/docs=x", "re:[=1"} {
+		if _, err := ParsePatternWeight(spec); err == nil {
+			t.Errorf("Expected error for pattern weight %q", spec)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package frontier
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMinRecrawlInterval and DefaultMaxRecrawlInterval bound the
+	// adaptive revisit interval when RecrawlPolicy leaves them zero
+	DefaultMinRecrawlInterval = 15 * time.Minute
+	DefaultMaxRecrawlInterval = 30 * 24 * time.Hour
+	// DefaultRecrawlFactor is how much the interval shrinks after a change
+	// and grows after an unchanged visit
+	DefaultRecrawlFactor = 2.0
+)
+
+// RecrawlPolicy bounds the adaptive revisit interval. The first revisit of a
+// page is scheduled after its RevisitPolicy delay, then the interval is
+// divided by Factor every time the page changed since the previous visit and
+// multiplied by Factor every time it did not.
+type RecrawlPolicy struct {
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	Factor      float64
+}
+
+func (p RecrawlPolicy) clamp(interval time.Duration) time.Duration {
+	minInterval, maxInterval := p.MinInterval, p.MaxInterval
+	if minInterval <= 0 {
+		minInterval = DefaultMinRecrawlInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = DefaultMaxRecrawlInterval
+	}
+	return min(max(interval, minInterval), max(minInterval, maxInterval))
+}
+
+func (p RecrawlPolicy) factor() float64 {
+	if p.Factor <= 1 {
+		return DefaultRecrawlFactor
+	}
+	return p.Factor
+}
+
+// WithRecrawl schedules revisits by how often each page reported to Visited
+// actually changes, and keeps the frontier running while revisits are
+// scheduled: due pages are queued again automatically until the frontier is
+// terminated. The schedule is kept in memory.
+func WithRecrawl(policy RecrawlPolicy) Option {
+	return func(f *Frontier) {
+		f.recrawl = &recrawlSchedule{
+			policy:  policy,
+			entries: make(map[string]*recrawlEntry),
+			wake:    make(chan struct{}, 1),
+		}
+	}
+}
+
+// WithRecrawlFile keeps the revisit schedule of WithRecrawl in the file at
+// path, so a resumed crawl keeps revisiting the pages it watched. The
+// schedule is loaded when the frontier is created and saved by Close.
+func WithRecrawlFile(path string) Option {
+	return func(f *Frontier) {
+		f.recrawlPath = path
+	}
+}
+
+// RecrawlInfo describes the revisit schedule of a page
+type RecrawlInfo struct {
+	Visits int
+	// Changes counts the visits whose content differed from the visit before
+	Changes   int
+	Interval  time.Duration
+	LastVisit time.Time
+	NextVisit time.Time
+}
+
+// ChangeRate is the fraction of revisits that found the page changed
+func (i RecrawlInfo) ChangeRate() float64 {
+	if i.Visits < 2 {
+		return 0
+	}
+	return float64(i.Changes) / float64(i.Visits-1)
+}
+
+type recrawlEntry struct {
+	// req is queued again, with a new discovery time, when the page is due
+	req *Request
+	key string
+	// fingerprint is zero until content was reported for the page
+	fingerprint uint64
+	info        RecrawlInfo
+	index       int
+}
+
+// recrawlRecord is the on-disk form of a recrawlEntry
+type recrawlRecord struct {
+	Request     queueRecord   `json:"request"`
+	Fingerprint uint64        `json:"fingerprint,omitempty"`
+	Visits      int           `json:"visits"`
+	Changes     int           `json:"changes"`
+	Interval    time.Duration `json:"interval"`
+	LastVisit   time.Time     `json:"last_visit"`
+	NextVisit   time.Time     `json:"next_visit"`
+}
+
+// recrawlSchedule holds the pages to revisit, the frontier's lock must be held
+type recrawlSchedule struct {
+	policy  RecrawlPolicy
+	entries map[string]*recrawlEntry
+	due     recrawlHeap
+	// wake interrupts the recrawl loop's wait when the earliest visit changes
+	wake chan struct{}
+}
+
+// recrawlHeap implements heap.Interface, earliest next visit first
+type recrawlHeap []*recrawlEntry
+
+func (h recrawlHeap) Len() int { return len(h) }
+
+func (h recrawlHeap) Less(i, j int) bool {
+	return h[i].info.NextVisit.Before(h[j].info.NextVisit)
+}
+
+func (h recrawlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *recrawlHeap) Push(x any) {
+	entry := x.(*recrawlEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *recrawlHeap) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*h = old[:n-1]
+	return entry
+}
+
+// fingerprint hashes page content to detect changes between visits
+func fingerprint(content []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(content)
+	return h.Sum64()
+}
+
+// Visited reports the content fetched for req. With WithRecrawl it compares
+// the content with the previous visit and schedules the next one, otherwise
+// it does nothing. Content should leave out parts that change on every
+// fetch, such as timestamps, or the page is revisited as often as allowed.
+func (f *Frontier) Visited(req *Request, content []byte) {
+	f.visited(req, fingerprint(content), false)
+}
+
+// NotModified reports that req was answered with 304 Not Modified. With
+// WithRecrawl it counts as a visit that found the page unchanged.
+func (f *Frontier) NotModified(req *Request) {
+	f.visited(req, 0, true)
+}
+
+// visited schedules the next visit of req after a visit that found content
+// with fingerprint sum, or the previous content if unchanged is set
+func (f *Frontier) visited(req *Request, sum uint64, unchanged bool) {
+	if f.recrawl == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+
+	s := f.recrawl
+	now := time.Now()
+	key := f.canon.Key(req.Url)
+	entry, ok := s.entries[key]
+	if unchanged && ok {
+		sum = entry.fingerprint
+	}
+	switch {
+	case !ok:
+		entry = &recrawlEntry{key: key, index: -1}
+		entry.info.Interval = s.policy.clamp(f.revisit.DelayFor(req))
+		s.entries[key] = entry
+	case entry.fingerprint != 0 && sum != entry.fingerprint:
+		entry.info.Changes++
+		entry.info.Interval = s.policy.clamp(time.Duration(float64(entry.info.Interval) / s.policy.factor()))
+	default:
+		entry.info.Interval = s.policy.clamp(time.Duration(float64(entry.info.Interval) * s.policy.factor()))
+	}
+	entry.req = req
+	entry.fingerprint = sum
+	entry.info.Visits++
+	entry.info.LastVisit = now
+	entry.info.NextVisit = now.Add(entry.info.Interval)
+
+	if err := f.history.Put(key, entry.info.NextVisit); err != nil {
+		log.WithField("url", req.Url).Errorf("Failed to record request: %s", err)
+	}
+	if entry.index < 0 {
+		heap.Push(&s.due, entry)
+	} else {
+		heap.Fix(&s.due, entry.index)
+	}
+	if entry.index == 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	log.WithFields(log.Fields{
+		"url":      req.Url,
+		"changes":  entry.info.Changes,
+		"visits":   entry.info.Visits,
+		"interval": entry.info.Interval,
+	}).Debug("Scheduled revisit")
+}
+
+// loadRecrawl adds the schedule saved at f.recrawlPath to f.recrawl. A
+// missing file holds no schedule.
+func (f *Frontier) loadRecrawl() error {
+	data, err := os.ReadFile(f.recrawlPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading revisit schedule: %w", err)
+	}
+	var records []recrawlRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("reading revisit schedule %s: %w", f.recrawlPath, err)
+	}
+	s := f.recrawl
+	for _, record := range records {
+		item, err := record.Request.queued()
+		if err != nil {
+			return fmt.Errorf("reading revisit schedule %s: %w", f.recrawlPath, err)
+		}
+		entry := &recrawlEntry{
+			req:         item.req,
+			key:         item.key,
+			fingerprint: record.Fingerprint,
+			info: RecrawlInfo{
+				Visits:    record.Visits,
+				Changes:   record.Changes,
+				Interval:  record.Interval,
+				LastVisit: record.LastVisit,
+				NextVisit: record.NextVisit,
+			},
+			index: -1,
+		}
+		s.entries[entry.key] = entry
+		heap.Push(&s.due, entry)
+	}
+	return nil
+}
+
+// saveRecrawl atomically writes f.recrawl to f.recrawlPath, f.mu must be held
+func (f *Frontier) saveRecrawl() error {
+	records := make([]recrawlRecord, 0, len(f.recrawl.entries))
+	for _, entry := range f.recrawl.entries {
+		records = append(records, recrawlRecord{
+			Request:     newQueueRecord(entry.req, entry.key, 0, 0),
+			Fingerprint: entry.fingerprint,
+			Visits:      entry.info.Visits,
+			Changes:     entry.info.Changes,
+			Interval:    entry.info.Interval,
+			LastVisit:   entry.info.LastVisit,
+			NextVisit:   entry.info.NextVisit,
+		})
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp := f.recrawlPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing revisit schedule: %w", err)
+	}
+	if err := os.Rename(tmp, f.recrawlPath); err != nil {
+		return fmt.Errorf("writing revisit schedule: %w", err)
+	}
+	return nil
+}
+
+// Recrawl returns the revisit schedule of u, false if it has none
+func (f *Frontier) Recrawl(u *url.URL) (RecrawlInfo, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.recrawl == nil {
+		return RecrawlInfo{}, false
+	}
+	entry, ok := f.recrawl.entries[f.canon.Key(u)]
+	if !ok {
+		return RecrawlInfo{}, false
+	}
+	return entry.info, true
+}
+
+// recrawlLoop queues scheduled pages again as they come due, until the
+// frontier is terminated
+func (f *Frontier) recrawlLoop() {
+	s := f.recrawl
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		f.mu.Lock()
+		now := time.Now()
+		var due []*Request
+		for len(s.due) > 0 && !s.due[0].info.NextVisit.After(now) {
+			entry := s.due[0]
+			revisit := *entry.req
+			revisit.DiscoveredAt = now
+			due = append(due, &revisit)
+			// Until the page is visited again, retry after another interval
+			// in case the revisit fails
+			entry.info.NextVisit = now.Add(entry.info.Interval)
+			heap.Fix(&s.due, 0)
+		}
+		wait := time.Hour
+		if len(s.due) > 0 {
+			wait = s.due[0].info.NextVisit.Sub(now)
+		}
+		f.mu.Unlock()
+
+		for _, req := range due {
+			f.Add(req)
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-f.stop:
+			return
+		}
+	}
+}
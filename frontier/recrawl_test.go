@@ -0,0 +1,173 @@
+package frontier
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecrawlAdaptsInterval tests that unchanged pages are revisited less often and changed ones more often
+func TestRecrawlAdaptsInterval(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: time.Hour}),
+		WithRecrawl(RecrawlPolicy{MinInterval: 15 * time.Minute, MaxInterval: 4 * time.Hour}))
+	defer f.Terminate()
+
+	u, _ := url.Parse("https://example.com/news")
+	req := NewRequest(u)
+	steps := []struct {
+		content  string
+		interval time.Duration
+	}{
+		{"v1", time.Hour},
+		{"v1", 2 * time.Hour},
+		{"v1", 4 * time.Hour},
+		{"v1", 4 * time.Hour},
+		{"v2", 2 * time.Hour},
+		{"v3", time.Hour},
+		{"v4", 30 * time.Minute},
+		{"v5", 15 * time.Minute},
+		{"v6", 15 * time.Minute},
+	}
+	for i, step := range steps {
+		f.Visited(req, []byte(step.content))
+		info, ok := f.Recrawl(u)
+		if !ok {
+			t.Fatal("Expected a scheduled revisit")
+		}
+		if info.Interval != step.interval {
+			t.Errorf("Visit %d: expected interval %s, got %s", i+1, step.interval, info.Interval)
+		}
+		if !f.Seen(u) {
+			t.Errorf("Visit %d: page should be seen until its next visit", i+1)
+		}
+	}
+
+	info, _ := f.Recrawl(u)
+	if info.Visits != len(steps) || info.Changes != 5 {
+		t.Errorf("Expected %d visits and 5 changes, got %d and %d", len(steps), info.Visits, info.Changes)
+	}
+	if rate := info.ChangeRate(); rate != 5.0/8 {
+		t.Errorf("Expected a change rate of 5/8, got %v", rate)
+	}
+
+	other, _ := url.Parse("https://example.com/other")
+	if _, ok := f.Recrawl(other); ok {
+		t.Error("Pages never visited should have no schedule")
+	}
+}
+
+// TestRecrawlNotModified tests that a 304 counts as an unchanged visit
+func TestRecrawlNotModified(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: time.Hour}),
+		WithRecrawl(RecrawlPolicy{MinInterval: 15 * time.Minute, MaxInterval: 4 * time.Hour}))
+	defer f.Terminate()
+
+	u, _ := url.Parse("https://example.com/news")
+	req := NewRequest(u)
+	steps := []struct {
+		// content is empty for a 304
+		content  string
+		interval time.Duration
+	}{
+		{"v1", time.Hour},
+		{"", 2 * time.Hour},
+		{"v1", 4 * time.Hour},
+		{"", 4 * time.Hour},
+		{"v2", 2 * time.Hour},
+	}
+	for i, step := range steps {
+		if step.content == "" {
+			f.NotModified(req)
+		} else {
+			f.Visited(req, []byte(step.content))
+		}
+		info, _ := f.Recrawl(u)
+		if info.Interval != step.interval {
+			t.Errorf("Visit %d: expected interval %s, got %s", i+1, step.interval, info.Interval)
+		}
+	}
+	if info, _ := f.Recrawl(u); info.Visits != len(steps) || info.Changes != 1 {
+		t.Errorf("Expected %d visits and 1 change, got %d and %d", len(steps), info.Visits, info.Changes)
+	}
+
+	// A page first answered with 304, e.g. with validators kept from an
+	// earlier crawl, does not count its first content as a change
+	other, _ := url.Parse("https://example.com/other")
+	f.NotModified(NewRequest(other))
+	f.Visited(NewRequest(other), []byte("v1"))
+	if info, _ := f.Recrawl(other); info.Visits != 2 || info.Changes != 0 {
+		t.Errorf("Expected 2 visits and no change, got %d and %d", info.Visits, info.Changes)
+	}
+}
+
+// TestRecrawlRequeuesDuePages tests that scheduled pages re-enter the frontier and keep it running
+func TestRecrawlRequeuesDuePages(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/")
+	f := NewFrontier([]url.URL{*seedURL}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: 50 * time.Millisecond}),
+		WithRecrawl(RecrawlPolicy{MinInterval: 50 * time.Millisecond, MaxInterval: time.Second}))
+	defer f.Terminate()
+
+	for visit := range 2 {
+		select {
+		case req, ok := <-f.Get():
+			if !ok {
+				t.Fatalf("Visit %d: frontier terminated while a revisit was scheduled", visit+1)
+			}
+			if req.Url.String() != seedURL.String() || req.Depth != 0 {
+				t.Errorf("Visit %d: unexpected request %s at depth %d", visit+1, req, req.Depth)
+			}
+			f.Visited(req, []byte("same"))
+			f.Done(req)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Visit %d: page was not queued again", visit+1)
+		}
+	}
+	if stats := f.Stats(); stats.Scheduled != 1 {
+		t.Errorf("Expected 1 scheduled page, got %d", stats.Scheduled)
+	}
+}
+
+// TestRecrawlFile tests that the revisit schedule is saved on Close and loaded by the next frontier
+func TestRecrawlFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), RecrawlFile)
+	policy := RecrawlPolicy{MinInterval: 15 * time.Minute, MaxInterval: 4 * time.Hour}
+	u, _ := url.Parse("https://example.com/news")
+
+	f := NewFrontier([]url.URL{}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: time.Hour}),
+		WithRecrawl(policy), WithRecrawlFile(path))
+	req := NewRequest(u)
+	req.Depth = 2
+	f.Visited(req, []byte("v1"))
+	f.Visited(req, []byte("v2"))
+	saved, _ := f.Recrawl(u)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f = NewFrontier([]url.URL{}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: time.Hour}),
+		WithRecrawl(policy), WithRecrawlFile(path))
+	defer f.Close()
+	info, ok := f.Recrawl(u)
+	if !ok {
+		t.Fatal("Expected the saved schedule to be loaded")
+	}
+	if info.Visits != saved.Visits || info.Changes != saved.Changes || info.Interval != saved.Interval ||
+		!info.NextVisit.Equal(saved.NextVisit) {
+		t.Errorf("Expected %+v, got %+v", saved, info)
+	}
+	if stats := f.Stats(); stats.Scheduled != 1 {
+		t.Errorf("Expected 1 scheduled page, got %d", stats.Scheduled)
+	}
+
+	// The page keeps its content fingerprint, so the same content is unchanged
+	f.Visited(req, []byte("v2"))
+	if info, _ := f.Recrawl(u); info.Changes != saved.Changes {
+		t.Errorf("Expected %d changes after an unchanged visit, got %d", saved.Changes, info.Changes)
+	}
+}
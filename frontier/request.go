@@ -0,0 +1,60 @@
+package frontier
+
+import (
+	"net/url"
+	"time"
+)
+
+// Request is a URL waiting to be crawled together with how it was discovered
+type Request struct {
+	Url *url.URL
+	// Depth is the number of links followed from the seed, seeds are depth 0
+	Depth int
+	// Parent is the page or sitemap the URL was found on, nil for seeds
+	Parent *url.URL
+	// Seed is the seed URL the request descends from, scopes are relative to it
+	Seed         *url.URL
+	DiscoveredAt time.Time
+
+	// MaxDepth and Scope override the frontier's max depth and scope kind for
+	// the requests descending from a seed. A zero MaxDepth and an empty Scope
+	// use the frontier's, a negative MaxDepth means unlimited.
+	MaxDepth int
+	Scope    ScopeKind
+
+	// LastMod, ChangeFreq and Priority are copied from the sitemap entry a
+	// request was seeded from and are zero for links found in pages
+	LastMod    time.Time
+	ChangeFreq string
+	Priority   float64
+}
+
+// NewRequest creates a seed request for the given URL
+func NewRequest(u *url.URL) *Request {
+	return &Request{
+		Url:          u,
+		Seed:         u,
+		DiscoveredAt: time.Now(),
+	}
+}
+
+// Child creates a request for a URL discovered on the page of this request
+func (r *Request) Child(u *url.URL) *Request {
+	seed := r.Seed
+	if seed == nil {
+		seed = r.Url
+	}
+	return &Request{
+		Url:          u,
+		Depth:        r.Depth + 1,
+		Parent:       r.Url,
+		Seed:         seed,
+		DiscoveredAt: time.Now(),
+		MaxDepth:     r.MaxDepth,
+		Scope:        r.Scope,
+	}
+}
+
+func (r *Request) String() string {
+	return r.Url.String()
+}
@@ -0,0 +1,136 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultRevisitDelay is used when no revisit delay is configured
+const DefaultRevisitDelay = 2 * time.Hour
+
+// RevisitRule overrides the revisit delay for URLs matching a host and path pattern
+type RevisitRule struct {
+	// Host is a glob matched against the URL hostname, empty matches any host
+	Host string
+	// Path is a glob matched against the URL path and each of its parent
+	// directories, so "/news/*" also covers "/news/2024/article". Empty
+	// matches any path.
+	Path  string
+	Delay time.Duration
+}
+
+func (r RevisitRule) matches(u *url.URL) bool {
+	if r.Host != "" {
+		if ok, _ := path.Match(r.Host, u.Hostname()); !ok {
+			return false
+		}
+	}
+	if r.Path == "" {
+		return true
+	}
+	// Relative paths, e.g. of a URL without a scheme, are matched as rooted
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	for {
+		if ok, _ := path.Match(r.Path, p); ok {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+func (r RevisitRule) String() string {
+	host := r.Host
+	if host == "" {
+		host = "*"
+	}
+	return fmt.Sprintf("%s%s=%s", host, r.Path, r.Delay)
+}
+
+// ParseRevisitRule parses a rule of the form "host/path=duration", for
+// example "news.example.com/news/*=1h" or "*/archive/*=168h". The host may
+// be omitted or "*" to match any host.
+func ParseRevisitRule(rule string) (RevisitRule, error) {
+	i := strings.LastIndex(rule, "=")
+	if i < 0 {
+		return RevisitRule{}, fmt.Errorf("revisit rule %q: expected pattern=duration", rule)
+	}
+	delay, err := time.ParseDuration(rule[i+1:])
+	if err != nil {
+		return RevisitRule{}, fmt.Errorf("revisit rule %q: %w", rule, err)
+	}
+
+	pattern := rule[:i]
+	host, rulePath := pattern, ""
+	if j := strings.Index(pattern, "/"); j >= 0 {
+		host, rulePath = pattern[:j], pattern[j:]
+	}
+	if host == "*" {
+		host = ""
+	}
+	if host == "" && rulePath == "" {
+		return RevisitRule{}, fmt.Errorf("revisit rule %q: empty pattern", rule)
+	}
+	if _, err := path.Match(host, ""); err != nil {
+		return RevisitRule{}, fmt.Errorf("revisit rule %q: %w", rule, err)
+	}
+	if _, err := path.Match(rulePath, ""); err != nil {
+		return RevisitRule{}, fmt.Errorf("revisit rule %q: %w", rule, err)
+	}
+
+	return RevisitRule{Host: host, Path: rulePath, Delay: delay}, nil
+}
+
+// RevisitPolicy decides how long a URL is considered seen after it was queued
+type RevisitPolicy struct {
+	Default time.Duration
+	// Rules are checked in order, the first match wins
+	Rules []RevisitRule
+}
+
+// DelayFor returns the revisit delay that applies to req. Configured rules
+// win over the sitemap change frequency, which wins over the default.
+func (p RevisitPolicy) DelayFor(req *Request) time.Duration {
+	for _, rule := range p.Rules {
+		if rule.matches(req.Url) {
+			return rule.Delay
+		}
+	}
+	if delay := changeFreqDelay(req.ChangeFreq); delay > 0 {
+		return delay
+	}
+	if p.Default > 0 {
+		return p.Default
+	}
+	return DefaultRevisitDelay
+}
+
+// changeFreqDelay converts a sitemap <changefreq> value to a revisit delay,
+// returning 0 for unknown values
+func changeFreqDelay(changeFreq string) time.Duration {
+	switch changeFreq {
+	case "always":
+		// Pages that change on every access are still not worth refetching
+		// more than a few times an hour
+		return 15 * time.Minute
+	case "hourly":
+		return time.Hour
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	case "monthly":
+		return 30 * 24 * time.Hour
+	case "yearly", "never":
+		return 365 * 24 * time.Hour
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package frontier
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestRevisitPolicyDelayFor tests that the first matching rule decides the delay
+func TestRevisitPolicyDelayFor(t *testing.T) {
+	policy := RevisitPolicy{
+		Default: 24 * time.Hour,
+		Rules: []RevisitRule{
+			{Host: "news.example.com", Path: "/news/*", Delay: time.Hour},
+			{Path: "/archive/*", Delay: 7 * 24 * time.Hour},
+			{Host: "*.blog.com", Delay: 12 * time.Hour},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		urlStr   string
+		expected time.Duration
+	}{
+		{"News section", "https://news.example.com/news/today", time.Hour},
+		{"Nested news article", "https://news.example.com/news/2024/05/story", time.Hour},
+		{"Other section on news host", "https://news.example.com/about", 24 * time.Hour},
+		{"Archive on any host", "https://other.com/archive/2019/post", 7 * 24 * time.Hour},
+		{"Subdomain glob", "https://team.blog.com/post", 12 * time.Hour},
+		{"Default", "https://example.com/", 24 * time.Hour},
+		{"Relative path", "archive/2019/post", 7 * 24 * time.Hour},
+		{"Relative path without rule", "example.com/page", 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.urlStr)
+			if got := policy.DelayFor(NewRequest(u)); got != tt.expected {
+				t.Errorf("Expected delay %v for %s, got %v", tt.expected, tt.urlStr, got)
+			}
+		})
+	}
+}
+
+// TestParseRevisitRule tests parsing of host/path=duration rules
+func TestParseRevisitRule(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected RevisitRule
+		wantErr  bool
+	}{
+		{"example.com/news/*=1h", RevisitRule{Host: "example.com", Path: "/news/*", Delay: time.Hour}, false},
+		{"*/archive/*=168h", RevisitRule{Path: "/archive/*", Delay: 168 * time.Hour}, false},
+		{"/archive=30m", RevisitRule{Path: "/archive", Delay: 30 * time.Minute}, false},
+		{"example.com=24h", RevisitRule{Host: "example.com", Delay: 24 * time.Hour}, false},
+		{"example.com", RevisitRule{}, true},
+		{"example.com=soon", RevisitRule{}, true},
+		{"*=1h", RevisitRule{}, true},
+		{"example.com/[=1h", RevisitRule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRevisitRule(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for rule %q", tt.rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rule != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, rule)
+			}
+		})
+	}
+}
+
+// TestFrontierRevisitPolicy tests that the frontier uses the configured revisit delay
+func TestFrontierRevisitPolicy(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/news/today")
+	f := NewFrontier([]url.URL{*seedURL}, []string{}, WithRevisitPolicy(RevisitPolicy{
+		Default: time.Hour,
+		Rules:   []RevisitRule{{Path: "/news/*", Delay: time.Millisecond}},
+	}))
+	<-f.Get()
+
+	time.Sleep(5 * time.Millisecond)
+	if f.Seen(seedURL) {
+		t.Error("URL should be revisitable once its rule delay has passed")
+	}
+}
+
+// TestFrontierEvictsExpiredHistory tests that expired entries are removed from the history
+func TestFrontierEvictsExpiredHistory(t *testing.T) {
+	seedURL, _ := url.Parse("https://example.com/")
+	f := NewFrontier([]url.URL{*seedURL}, []string{})
+	<-f.Get()
+
+	expiredURL, _ := url.Parse("https://example.com/expired")
+	f.history.Put(f.canon.Key(expiredURL), time.Now().Add(-time.Second))
+	f.lastEvict = time.Now().Add(-2 * evictInterval)
+
+	f.evictExpired()
+
+	if _, ok := f.history.Get(f.canon.Key(expiredURL)); ok {
+		t.Error("Expired entry should have been evicted")
+	}
+	if _, ok := f.history.Get(f.canon.Key(seedURL)); !ok {
+		t.Error("Unexpired entry should be kept")
+	}
+}
+
+// TestRevisitPolicyChangeFreq tests that sitemap change frequencies fall between rules and the default
+func TestRevisitPolicyChangeFreq(t *testing.T) {
+	policy := RevisitPolicy{
+		Default: 24 * time.Hour,
+		Rules:   []RevisitRule{{Path: "/pinned", Delay: time.Minute}},
+	}
+
+	hourlyURL, _ := url.Parse("https://example.com/news")
+	hourly := NewRequest(hourlyURL)
+	hourly.ChangeFreq = "hourly"
+	if got := policy.DelayFor(hourly); got != time.Hour {
+		t.Errorf("Expected hourly change frequency to give 1h, got %v", got)
+	}
+
+	pinnedURL, _ := url.Parse("https://example.com/pinned")
+	pinned := NewRequest(pinnedURL)
+	pinned.ChangeFreq = "yearly"
+	if got := policy.DelayFor(pinned); got != time.Minute {
+		t.Errorf("Expected rule to win over change frequency, got %v", got)
+	}
+
+	unknown := NewRequest(hourlyURL)
+	unknown.ChangeFreq = "sometimes"
+	if got := policy.DelayFor(unknown); got != 24*time.Hour {
+		t.Errorf("Expected unknown change frequency to use the default, got %v", got)
+	}
+}
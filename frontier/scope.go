@@ -0,0 +1,225 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Target is the part of a URL a scope rule is matched against
+type Target string
+
+const (
+	TargetHost Target = "host"
+	TargetPath Target = "path"
+	TargetUrl  Target = "url"
+)
+
+// Rule matches URLs by host, path or full URL using a glob or a regular expression
+type Rule struct {
+	// Pattern is the rule as it was written, used when reporting rejections
+	Pattern string
+	Target  Target
+	re      *regexp.Regexp
+}
+
+// ParseRule parses a scope rule. An optional "host:", "path:" or "url:"
+// prefix selects what is matched, followed by either "re:<regexp>" or a glob
+// where "*" matches anything and "?" a single character. Without a prefix,
+// patterns containing "://" match the full URL, patterns starting with "/"
+// match the path and anything else matches the host.
+//
+// Host globs match the whole hostname, so "*.example.com" covers subdomains
+// but not example.com itself. Path globs also match everything below the
+// pattern, so "/admin" covers "/admin/users". Regular expressions are
+// unanchored.
+func ParseRule(pattern string) (*Rule, error) {
+	rule := &Rule{Pattern: pattern}
+	expr := pattern
+
+	for _, target := range []Target{TargetHost, TargetPath, TargetUrl} {
+		if prefix := string(target) + ":"; strings.HasPrefix(expr, prefix) {
+			rule.Target = target
+			expr = expr[len(prefix):]
+			break
+		}
+	}
+
+	if regex, ok := strings.CutPrefix(expr, "re:"); ok {
+		if rule.Target == "" {
+			rule.Target = TargetUrl
+		}
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, fmt.Errorf("scope rule %q: %w", pattern, err)
+		}
+		rule.re = re
+		return rule, nil
+	}
+
+	if expr == "" {
+		return nil, fmt.Errorf("scope rule %q: empty pattern", pattern)
+	}
+	if rule.Target == "" {
+		switch {
+		case strings.Contains(expr, "://"):
+			rule.Target = TargetUrl
+		case strings.HasPrefix(expr, "/"):
+			rule.Target = TargetPath
+		default:
+			rule.Target = TargetHost
+		}
+	}
+
+	regex := globToRegexp(expr)
+	switch rule.Target {
+	case TargetHost:
+		regex = "(?i)^" + regex + "$"
+	case TargetPath:
+		regex = "^" + strings.TrimSuffix(regex, "/") + "(/.*)?$"
+	default:
+		regex = "^" + regex + "$"
+	}
+	rule.re = regexp.MustCompile(regex)
+	return rule, nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// Matches reports whether u matches the rule
+func (r *Rule) Matches(u *url.URL) bool {
+	switch r.Target {
+	case TargetHost:
+		// Patterns may or may not include the port
+		return r.re.MatchString(u.Hostname()) || r.re.MatchString(u.Host)
+	case TargetPath:
+		p := u.Path
+		if p == "" {
+			p = "/"
+		}
+		return r.re.MatchString(p)
+	default:
+		return r.re.MatchString(u.String())
+	}
+}
+
+func (r *Rule) String() string {
+	return r.Pattern
+}
+
+// ScopeKind is a built-in scope restricting links relative to their seed
+type ScopeKind string
+
+const (
+	// ScopeAny follows links anywhere
+	ScopeAny ScopeKind = "any"
+	// ScopeSameHost follows links on the seed's exact host
+	ScopeSameHost ScopeKind = "same-host"
+	// ScopeSameDomain follows links on the seed's registered domain, e.g.
+	// docs.example.co.uk for a seed on www.example.co.uk
+	ScopeSameDomain ScopeKind = "same-domain"
+	// ScopeSeedPath follows links on the seed's host below the seed's directory
+	ScopeSeedPath ScopeKind = "seed-path"
+)
+
+// ParseScopeKind validates the name of a built-in scope, an empty name is ScopeAny
+func ParseScopeKind(name string) (ScopeKind, error) {
+	switch kind := ScopeKind(strings.ToLower(name)); kind {
+	case "":
+		return ScopeAny, nil
+	case ScopeAny, ScopeSameHost, ScopeSameDomain, ScopeSeedPath:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown scope %q, expected one of %s, %s, %s, %s",
+		name, ScopeAny, ScopeSameHost, ScopeSameDomain, ScopeSeedPath)
+}
+
+// contains reports whether u is within the scope kind around seed
+func (k ScopeKind) contains(seed *url.URL, u *url.URL) bool {
+	switch k {
+	case ScopeSameHost:
+		return strings.EqualFold(seed.Hostname(), u.Hostname())
+	case ScopeSameDomain:
+		return strings.EqualFold(registeredDomain(seed.Hostname()), registeredDomain(u.Hostname()))
+	case ScopeSeedPath:
+		if !strings.EqualFold(seed.Host, u.Host) {
+			return false
+		}
+		dir := seed.Path
+		if i := strings.LastIndex(dir, "/"); i >= 0 {
+			dir = dir[:i+1]
+		}
+		return strings.HasPrefix(u.Path, dir)
+	}
+	return true
+}
+
+func registeredDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		// IP addresses and bare public suffixes are their own domain
+		return strings.ToLower(host)
+	}
+	return domain
+}
+
+// Scope decides which discovered URLs belong to the crawl
+type Scope struct {
+	Kind ScopeKind
+	// Include rules, when present, must match at least one of them
+	Include []*Rule
+	// Exclude rules refuse any URL they match, seeds included
+	Exclude []*Rule
+}
+
+// Check returns false and the reason when req is out of scope, using the
+// request's own scope kind if it has one. Seeds are only checked against
+// exclude rules.
+func (s *Scope) Check(req *Request) (bool, string) {
+	for _, rule := range s.Exclude {
+		if rule.Matches(req.Url) {
+			return false, "excluded by " + rule.String()
+		}
+	}
+	if req.Depth == 0 {
+		return true, ""
+	}
+
+	seed := req.Seed
+	if seed == nil {
+		seed = req.Url
+	}
+	kind := s.Kind
+	if req.Scope != "" {
+		kind = req.Scope
+	}
+	if !kind.contains(seed, req.Url) {
+		return false, "outside " + string(kind) + " scope"
+	}
+
+	if len(s.Include) == 0 {
+		return true, ""
+	}
+	for _, rule := range s.Include {
+		if rule.Matches(req.Url) {
+			return true, ""
+		}
+	}
+	return false, "no include rule matched"
+}
@@ -0,0 +1,164 @@
+package frontier
+
+import (
+	"net/url"
+	"testing"
+)
+
+// TestParseRuleMatches tests glob and regex rules against hosts, paths and full URLs
+func TestParseRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		urlStr  string
+		matches bool
+	}{
+		{"example.com", "https://example.com/page", true},
+		{"example.com", "https://sub.example.com/page", false},
+		{"Example.COM", "https://example.com/", true},
+		{"*.example.com", "https://sub.example.com/", true},
+		{"*.example.com", "https://example.com/", false},
+		{"localhost:8080", "http://localhost:8080/", true},
+		{"/login", "https://example.com/login", true},
+		{"/login", "https://example.com/login/reset", true},
+		{"/login", "https://example.com/login-help", false},
+		{"/admin/", "https://example.com/admin", true},
+		{"/docs/*.pdf", "https://example.com/docs/guides/intro.pdf", true},
+		{"/docs/*.pdf", "https://example.com/docs/intro.html", false},
+		{"https://example.com/private/*", "https://example.com/private/a", true},
+		{"https://example.com/private/*", "http://example.com/private/a", false},
+		{"re:[?&](sort|filter)=", "https://example.com/list?page=2&sort=asc", true},
+		{"re:[?&](sort|filter)=", "https://example.com/list?page=2", false},
+		{"path:re:^/\\d{4}/\\d{2}/", "https://example.com/2024/05/post", true},
+		{"host:re:^(www\\.)?example\\.org$", "https://www.example.org/", true},
+		{"path:*.php", "https://example.com/index.php", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.urlStr, func(t *testing.T) {
+			rule, err := ParseRule(tt.pattern)
+			if err != nil {
+				t.Fatalf("Failed to parse rule: %v", err)
+			}
+			u, _ := url.Parse(tt.urlStr)
+			if got := rule.Matches(u); got != tt.matches {
+				t.Errorf("Rule %q on %s: expected %v, got %v", tt.pattern, tt.urlStr, tt.matches, got)
+			}
+		})
+	}
+}
+
+// TestParseRuleErrors tests that invalid rules are refused
+func TestParseRuleErrors(t *testing.T) {
+	for _, pattern := range []string{"", "re:(unclosed", "path:"} {
+		if _, err := ParseRule(pattern); err == nil {
+			t.Errorf("Expected error for pattern %q", pattern)
+		}
+	}
+}
+
+// TestScopeKinds tests the built-in scopes relative to a seed
+func TestScopeKinds(t *testing.T) {
+	seed, _ := url.Parse("https://www.example.co.uk/docs/intro.html")
+	seedReq := NewRequest(seed)
+
+	tests := []struct {
+		kind    ScopeKind
+		urlStr  string
+		inScope bool
+	}{
+		{ScopeAny, "https://other.com/", true},
+		{ScopeSameHost, "https://www.example.co.uk/about", true},
+		{ScopeSameHost, "https://blog.example.co.uk/", false},
+		{ScopeSameDomain, "https://blog.example.co.uk/", true},
+		{ScopeSameDomain, "https://example.co.uk/", true},
+		{ScopeSameDomain, "https://other.co.uk/", false},
+		{ScopeSeedPath, "https://www.example.co.uk/docs/api/ref", true},
+		{ScopeSeedPath, "https://www.example.co.uk/blog/", false},
+		{ScopeSeedPath, "https://blog.example.co.uk/docs/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+" "+tt.urlStr, func(t *testing.T) {
+			scope := &Scope{Kind: tt.kind}
+			u, _ := url.Parse(tt.urlStr)
+			ok, reason := scope.Check(seedReq.Child(u))
+			if ok != tt.inScope {
+				t.Errorf("Expected in scope=%v, got %v (%s)", tt.inScope, ok, reason)
+			}
+		})
+	}
+}
+
+// TestScopePerSeed tests that a seed's own scope kind overrides the frontier's
+func TestScopePerSeed(t *testing.T) {
+	scope := &Scope{Kind: ScopeAny}
+	seed, _ := url.Parse("https://example.com/")
+	seedReq := NewRequest(seed)
+	seedReq.Scope = ScopeSameHost
+
+	other, _ := url.Parse("https://other.com/")
+	if ok, reason := scope.Check(seedReq.Child(other)); ok || reason != "outside same-host scope" {
+		t.Errorf("Expected the seed's same-host scope to apply, got ok=%v reason=%q", ok, reason)
+	}
+	if ok, _ := scope.Check(NewRequest(seed).Child(other)); !ok {
+		t.Error("Seeds without their own scope should use the frontier's")
+	}
+}
+
+// TestScopeIncludeExclude tests include and exclude rules together
+func TestScopeIncludeExclude(t *testing.T) {
+	include, _ := ParseRule("/docs")
+	exclude, _ := ParseRule("/docs/internal")
+	scope := &Scope{Kind: ScopeSameHost, Include: []*Rule{include}, Exclude: []*Rule{exclude}}
+
+	seed, _ := url.Parse("https://example.com/")
+	seedReq := NewRequest(seed)
+	if ok, reason := scope.Check(seedReq); !ok {
+		t.Errorf("Seed should only be checked against exclude rules, got %s", reason)
+	}
+
+	docs, _ := url.Parse("https://example.com/docs/guide")
+	if ok, reason := scope.Check(seedReq.Child(docs)); !ok {
+		t.Errorf("Expected /docs/guide in scope, got %s", reason)
+	}
+
+	internal, _ := url.Parse("https://example.com/docs/internal/secrets")
+	if ok, reason := scope.Check(seedReq.Child(internal)); ok || reason != "excluded by /docs/internal" {
+		t.Errorf("Expected exclusion by /docs/internal, got ok=%v reason=%q", ok, reason)
+	}
+
+	blog, _ := url.Parse("https://example.com/blog")
+	if ok, reason := scope.Check(seedReq.Child(blog)); ok || reason != "no include rule matched" {
+		t.Errorf("Expected /blog to match no include rule, got ok=%v reason=%q", ok, reason)
+	}
+}
+
+// TestFrontierCountsRejections tests that rejected requests are counted by reason
+func TestFrontierCountsRejections(t *testing.T) {
+	seed, _ := url.Parse("https://example.com/")
+	f := NewFrontier([]url.URL{*seed}, []string{"/admin"}, WithScope(Scope{Kind: ScopeSameHost}))
+	seedReq := <-f.Get()
+
+	for _, link := range []string{
+		"https://example.com/admin/users",
+		"https://example.com/admin",
+		"https://other.com/",
+	} {
+		u, _ := url.Parse(link)
+		if f.Add(seedReq.Child(u)) {
+			t.Errorf("Expected %s to be rejected", link)
+		}
+	}
+	f.Add(NewRequest(seed))
+
+	rejected := f.Rejected()
+	if rejected["excluded by /admin"] != 2 {
+		t.Errorf("Expected 2 exclusions, got %v", rejected)
+	}
+	if rejected["outside same-host scope"] != 1 {
+		t.Errorf("Expected 1 out of scope rejection, got %v", rejected)
+	}
+	if rejected["already seen"] != 1 {
+		t.Errorf("Expected 1 duplicate rejection, got %v", rejected)
+	}
+}
@@ -0,0 +1,190 @@
+package frontier
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// QueueDir and HistoryFile are where OpenDir keeps the queue and the
+	// seen history inside a frontier state directory, RecrawlFile is where
+	// the revisit schedule of a watching crawl is kept next to them
+	QueueDir    = "queue"
+	HistoryFile = "seen.db"
+	RecrawlFile = "recrawl.json"
+)
+
+// OpenDir opens the DiskQueue and DiskHistory kept in a frontier state
+// directory, creating them if needed
+func OpenDir(dir string) (*DiskQueue, *DiskHistory, error) {
+	queue, err := NewDiskQueue(filepath.Join(dir, QueueDir), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	history, err := NewDiskHistory(filepath.Join(dir, HistoryFile))
+	if err != nil {
+		queue.Close()
+		return nil, nil, err
+	}
+	return queue, history, nil
+}
+
+// Stats is a snapshot of what a frontier holds
+type Stats struct {
+	// Queued requests wait to be dispatched
+	Queued int `json:"queued"`
+	// InFlight requests were dispatched but are not done yet
+	InFlight int `json:"in_flight"`
+	// Seen is the number of URLs in the seen history
+	Seen int `json:"seen"`
+	// Hosts counts queued requests by host
+	Hosts map[string]int `json:"hosts"`
+	// Rejected counts refused requests by reason
+	Rejected map[string]int `json:"rejected,omitempty"`
+	// Deferred requests wait for a filter that could not decide on them yet
+	Deferred int `json:"deferred,omitempty"`
+	// Scheduled is the number of pages with a revisit scheduled by WithRecrawl
+	Scheduled int `json:"scheduled,omitempty"`
+	// Oldest is the queued or in-flight request discovered first
+	Oldest     *PendingUrl `json:"oldest_pending,omitempty"`
+	Terminated bool        `json:"terminated"`
+}
+
+// PendingUrl describes a request that is queued or in flight
+type PendingUrl struct {
+	Url          string    `json:"url"`
+	Depth        int       `json:"depth"`
+	Parent       string    `json:"parent,omitempty"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+func newPendingUrl(req *Request) *PendingUrl {
+	if req == nil {
+		return nil
+	}
+	pending := &PendingUrl{Url: req.Url.String(), Depth: req.Depth, DiscoveredAt: req.DiscoveredAt}
+	if req.Parent != nil {
+		pending.Parent = req.Parent.String()
+	}
+	return pending
+}
+
+// HostCount is the number of queued requests for a host
+type HostCount struct {
+	Host  string `json:"host"`
+	Count int    `json:"count"`
+}
+
+// TopHosts returns the n hosts with the most queued requests, all of them if
+// n is not positive
+func (s Stats) TopHosts(n int) []HostCount {
+	hosts := make([]HostCount, 0, len(s.Hosts))
+	for host, count := range s.Hosts {
+		hosts = append(hosts, HostCount{Host: host, Count: count})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Count != hosts[j].Count {
+			return hosts[i].Count > hosts[j].Count
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+	if n > 0 && len(hosts) > n {
+		hosts = hosts[:n]
+	}
+	return hosts
+}
+
+// Stats returns a snapshot of the queue, the requests in flight and the seen
+// history. It walks the in-memory queue, so it is meant for diagnostics
+// rather than to be called for every request.
+func (f *Frontier) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	queueStats := f.queue.Stats()
+	stats := Stats{
+		Queued:     queueStats.Len,
+		InFlight:   len(f.inflight),
+		Deferred:   len(f.deferred),
+		Seen:       f.history.Len(),
+		Hosts:      queueStats.Hosts,
+		Rejected:   make(map[string]int, len(f.rejected)),
+		Terminated: f.terminating,
+	}
+	for reason, count := range f.rejected {
+		stats.Rejected[reason] = count
+	}
+	if f.recrawl != nil {
+		stats.Scheduled = len(f.recrawl.entries)
+	}
+
+	oldest := queueStats.Oldest
+	for req := range f.inflight {
+		if oldest == nil || req.DiscoveredAt.Before(oldest.DiscoveredAt) {
+			oldest = req
+		}
+	}
+	stats.Oldest = newPendingUrl(oldest)
+	return stats
+}
+
+// InspectDir reads the stats of a frontier state directory without
+// modifying it, so it is safe to use while a crawl is running on it.
+// The queue is read as of its last checkpoint, so requests dispatched since
+// then still count as queued. Rejections are not persisted.
+func InspectDir(dir string) (Stats, error) {
+	queueDir := filepath.Join(dir, QueueDir)
+	if _, err := os.Stat(queueDir); err != nil {
+		return Stats{}, fmt.Errorf("no frontier in %s: %w", dir, err)
+	}
+	state, err := loadQueueState(queueDir)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{Queued: state.onDisk, Hosts: state.hosts}
+	oldest := state.oldest
+	for _, record := range state.checkpoint.Pending {
+		item, err := record.queued()
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.Queued++
+		stats.Hosts[queueHost(item.req)]++
+		if oldest == nil || item.req.DiscoveredAt.Before(oldest.DiscoveredAt) {
+			oldest = item.req
+		}
+	}
+	stats.Oldest = newPendingUrl(oldest)
+
+	if stats.Seen, err = readHistoryLen(filepath.Join(dir, HistoryFile)); err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+// readHistoryLen reads the entry count from the header of a DiskHistory file
+func readHistoryLen(path string) (int, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("opening history: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, historyHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return 0, fmt.Errorf("reading history: %w", err)
+	}
+	if string(header[:8]) != historyMagic {
+		return 0, fmt.Errorf("reading history: %s is not a history file", path)
+	}
+	return int(binary.LittleEndian.Uint64(header[16:])), nil
+}
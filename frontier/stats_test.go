@@ -0,0 +1,101 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// TestFrontierStats tests the snapshot of queued, in-flight, seen and rejected URLs
+func TestFrontierStats(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{})
+	defer f.Terminate()
+
+	for _, raw := range []string{"https://a.com/1", "https://a.com/2", "https://b.com/1", "https://a.com/1"} {
+		u, _ := url.Parse(raw)
+		f.Add(NewRequest(u))
+	}
+	first := <-f.Get()
+
+	stats := f.Stats()
+	if stats.Queued+stats.InFlight != 3 {
+		t.Errorf("Expected 3 queued or in-flight requests, got %d queued and %d in flight", stats.Queued, stats.InFlight)
+	}
+	if stats.InFlight < 1 {
+		t.Errorf("Expected the received request to be in flight, got %d", stats.InFlight)
+	}
+	if stats.Seen != 3 {
+		t.Errorf("Expected 3 seen URLs, got %d", stats.Seen)
+	}
+	if stats.Rejected["already seen"] != 1 {
+		t.Errorf("Expected 1 duplicate rejection, got %v", stats.Rejected)
+	}
+	hosts := 0
+	for _, count := range stats.Hosts {
+		hosts += count
+	}
+	if hosts != stats.Queued {
+		t.Errorf("Host counts %v do not add up to %d queued requests", stats.Hosts, stats.Queued)
+	}
+	if stats.Oldest == nil || stats.Oldest.Url != first.Url.String() {
+		t.Errorf("Expected the oldest pending URL to be %s, got %+v", first, stats.Oldest)
+	}
+
+	f.Done(first)
+	if stats := f.Stats(); stats.Oldest == nil || stats.Oldest.Url == first.Url.String() {
+		t.Errorf("Done requests should not be pending, got %+v", stats.Oldest)
+	}
+}
+
+// TestInspectDir tests reading the stats of a frontier state directory
+func TestInspectDir(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := InspectDir(dir); err == nil {
+		t.Error("Expected an error for a directory without a frontier")
+	}
+
+	queue, history, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to open frontier: %v", err)
+	}
+	seedURL, _ := url.Parse("https://example.com/")
+	seed := NewRequest(seedURL)
+	for i := range 4 {
+		host := "example.com"
+		if i == 3 {
+			host = "other.com"
+		}
+		u, _ := url.Parse(fmt.Sprintf("https://%s/page%d", host, i))
+		queue.Push(seed.Child(u), u.String(), 0)
+		history.Put(u.String(), seed.DiscoveredAt)
+	}
+	done, _ := queue.Pop()
+	queue.Done(done)
+	queue.Close()
+	history.Close()
+
+	stats, err := InspectDir(dir)
+	if err != nil {
+		t.Fatalf("InspectDir failed: %v", err)
+	}
+	if stats.Queued != 3 {
+		t.Errorf("Expected 3 queued requests, got %d", stats.Queued)
+	}
+	if stats.Hosts["example.com"] != 2 || stats.Hosts["other.com"] != 1 {
+		t.Errorf("Unexpected host counts: %v", stats.Hosts)
+	}
+	if stats.Seen != 4 {
+		t.Errorf("Expected 4 seen URLs, got %d", stats.Seen)
+	}
+	if stats.Oldest == nil || stats.Oldest.Url != "https://example.com/page1" || stats.Oldest.Parent != seedURL.String() {
+		t.Errorf("Unexpected oldest pending URL: %+v", stats.Oldest)
+	}
+	if top := stats.TopHosts(1); len(top) != 1 || top[0].Host != "example.com" {
+		t.Errorf("Unexpected top hosts: %v", top)
+	}
+
+	if _, err := InspectDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
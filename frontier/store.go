@@ -0,0 +1,164 @@
+package frontier
+
+import (
+	"container/heap"
+	"strings"
+	"time"
+)
+
+// Queue holds accepted requests until they are dispatched. The frontier
+// serializes all calls, implementations need not be safe for concurrent use.
+type Queue interface {
+	// Push queues req under its canonical key with the given score
+	Push(req *Request, key string, score float64) error
+	// Pop removes the highest scoring request, or the oldest of equal
+	// scores, returning nil when the queue is empty
+	Pop() (*Request, error)
+	// Done is called once a popped request has been fully handled
+	Done(req *Request)
+	// Rescore recomputes the score of the queued request with key, if the
+	// queue still holds it in a form that can be reordered
+	Rescore(key string, score func(*Request) float64)
+	// Len returns the number of queued requests
+	Len() int
+	Stats() QueueStats
+	Close() error
+}
+
+// QueueStats describes the requests waiting in a Queue
+type QueueStats struct {
+	Len int
+	// Hosts counts queued requests by host
+	Hosts map[string]int
+	// Oldest is the queued request discovered first, nil if the queue is empty
+	Oldest *Request
+}
+
+// queueHost is the host requests are counted under in QueueStats
+func queueHost(req *Request) string {
+	return strings.ToLower(req.Url.Host)
+}
+
+// History records when each seen URL may be revisited, by canonical key. The
+// frontier serializes all calls.
+type History interface {
+	Get(key string) (revisitAt time.Time, ok bool)
+	Put(key string, revisitAt time.Time) error
+	// Evict may drop entries whose revisit time is before now
+	Evict(now time.Time)
+	Len() int
+	Close() error
+}
+
+// WithQueue replaces the in-memory queue, e.g. with a DiskQueue
+func WithQueue(queue Queue) Option {
+	return func(f *Frontier) {
+		f.queue = queue
+	}
+}
+
+// WithHistory replaces the in-memory seen history, e.g. with a DiskHistory
+func WithHistory(history History) Option {
+	return func(f *Frontier) {
+		f.history = history
+	}
+}
+
+// memoryQueue is the default unbounded in-memory Queue
+type memoryQueue struct {
+	items priorityQueue
+	// keys indexes queued items by canonical URL so they can be rescored
+	keys  map[string]*queued
+	hosts map[string]int
+	seq   uint64
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{keys: make(map[string]*queued), hosts: make(map[string]int)}
+}
+
+func (q *memoryQueue) Push(req *Request, key string, score float64) error {
+	item := &queued{req: req, key: key, score: score, seq: q.seq}
+	q.seq++
+	heap.Push(&q.items, item)
+	q.keys[key] = item
+	q.hosts[queueHost(req)]++
+	return nil
+}
+
+func (q *memoryQueue) Pop() (*Request, error) {
+	if len(q.items) == 0 {
+		return nil, nil
+	}
+	item := heap.Pop(&q.items).(*queued)
+	// A URL whose revisit delay passed while it was queued can be queued twice
+	if q.keys[item.key] == item {
+		delete(q.keys, item.key)
+	}
+	countOut(q.hosts, queueHost(item.req))
+	return item.req, nil
+}
+
+// countOut decrements the count of key, removing it at zero
+func countOut(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+func (q *memoryQueue) Done(*Request) {}
+
+func (q *memoryQueue) Rescore(key string, score func(*Request) float64) {
+	if item, ok := q.keys[key]; ok {
+		item.score = score(item.req)
+		heap.Fix(&q.items, item.index)
+	}
+}
+
+func (q *memoryQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *memoryQueue) Stats() QueueStats {
+	stats := QueueStats{Len: len(q.items), Hosts: make(map[string]int, len(q.hosts))}
+	for host, count := range q.hosts {
+		stats.Hosts[host] = count
+	}
+	stats.Oldest = q.items.oldest()
+	return stats
+}
+
+func (q *memoryQueue) Close() error {
+	return nil
+}
+
+// memoryHistory is the default in-memory History
+type memoryHistory map[string]time.Time
+
+func (h memoryHistory) Get(key string) (time.Time, bool) {
+	revisitAt, ok := h[key]
+	return revisitAt, ok
+}
+
+func (h memoryHistory) Put(key string, revisitAt time.Time) error {
+	h[key] = revisitAt
+	return nil
+}
+
+func (h memoryHistory) Evict(now time.Time) {
+	for key, revisitAt := range h {
+		if !now.Before(revisitAt) {
+			delete(h, key)
+		}
+	}
+}
+
+func (h memoryHistory) Len() int {
+	return len(h)
+}
+
+func (h memoryHistory) Close() error {
+	return nil
+}
@@ -0,0 +1,250 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// trapSamples is the number of example URLs kept per detected trap
+	trapSamples = 5
+	// maxTrapKeys bounds the templates, paths and reports tracked. Beyond it
+	// an arbitrary entry is forgotten for every new one.
+	maxTrapKeys = 10000
+)
+
+// TrapConfig limits the URL shapes that typically come from unbounded URL
+// spaces such as calendars, faceted search and session IDs. Zero disables a
+// limit. Seeds are never checked.
+type TrapConfig struct {
+	// MaxUrlLength is the longest URL accepted, in bytes
+	MaxUrlLength int
+	// MaxPathDepth is the most path segments accepted
+	MaxPathDepth int
+	// MaxRepeatedSegments is how often one path segment may occur in a path,
+	// catching relative link loops such as /a/b/a/b/a/b
+	MaxRepeatedSegments int
+	// MaxUrlsPerTemplate is the most URLs accepted per host and path
+	// template, where numbers and IDs in the path are wildcards and the query
+	// is reduced to its parameter names, so /calendar/2024/05 and
+	// /calendar/2031/11 share a template
+	MaxUrlsPerTemplate int
+	// MaxQueryVariants is the most distinct queries accepted for one host and
+	// path, catching faceted search parameter combinations
+	MaxQueryVariants int
+}
+
+// TrapReport describes URLs refused by a trap heuristic
+type TrapReport struct {
+	Heuristic string
+	// Pattern is the host and path template the refused URLs share
+	Pattern string
+	Count   int
+	// Samples are the first refused URLs, to help write exclusion rules
+	Samples []string
+}
+
+func (r TrapReport) String() string {
+	return fmt.Sprintf("%s: %s (%d URLs, e.g. %s)", r.Heuristic, r.Pattern, r.Count, strings.Join(r.Samples, " "))
+}
+
+// WithTrapDetection refuses URLs that look like crawler traps, see Traps for
+// what was refused
+func WithTrapDetection(config TrapConfig) Option {
+	return func(f *Frontier) {
+		f.traps = newTrapDetector(config)
+	}
+}
+
+var (
+	numberSegment = regexp.MustCompile(`^[0-9]+$`)
+	idSegment     = regexp.MustCompile(`^(?i:[0-9a-f]{8,}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+	dateSegment   = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}(-[0-9]{1,2})?$`)
+)
+
+// trapDetector keeps the per-template counts behind the trap heuristics, the
+// frontier's lock must be held
+type trapDetector struct {
+	config TrapConfig
+	// templates holds hashes of the distinct canonical URLs accepted per
+	// template, up to the limit, so revisits are not counted again
+	templates map[string]hashSet
+	// queries holds hashes of the distinct queries seen per host and path,
+	// up to the limit
+	queries map[string]hashSet
+	reports map[string]*TrapReport
+}
+
+// hashSet is a set of 64-bit hashes
+type hashSet map[uint64]struct{}
+
+func newTrapDetector(config TrapConfig) *trapDetector {
+	return &trapDetector{
+		config:    config,
+		templates: make(map[string]hashSet),
+		queries:   make(map[string]hashSet),
+		reports:   make(map[string]*TrapReport),
+	}
+}
+
+// check returns false and the reason when req, with canonical key, looks
+// like a trap, otherwise it counts req against its template
+func (d *trapDetector) check(req *Request, key string) (bool, string) {
+	if req.Depth == 0 {
+		return true, ""
+	}
+	u := req.Url
+	segments := pathSegments(u.Path)
+	template := pathTemplate(u, segments)
+
+	if limit := d.config.MaxUrlLength; limit > 0 && len(u.String()) > limit {
+		return d.trap(req, "url too long", template)
+	}
+	if limit := d.config.MaxPathDepth; limit > 0 && len(segments) > limit {
+		return d.trap(req, "path too deep", template)
+	}
+	if limit := d.config.MaxRepeatedSegments; limit > 0 {
+		counts := make(map[string]int, len(segments))
+		for _, segment := range segments {
+			counts[segment]++
+			if counts[segment] > limit {
+				return d.trap(req, "repeated path segment", template)
+			}
+		}
+	}
+
+	queryTemplate := template + queryNames(u)
+	urls := d.templates[queryTemplate]
+	urlHash := fingerprint([]byte(key))
+	if limit := d.config.MaxUrlsPerTemplate; limit > 0 {
+		if _, ok := urls[urlHash]; !ok && len(urls) >= limit {
+			return d.trap(req, "too many urls per template", queryTemplate)
+		}
+	}
+	pathKey := strings.ToLower(u.Host) + u.Path
+	variants := d.queries[pathKey]
+	queryHash := fingerprint([]byte(u.RawQuery))
+	if limit := d.config.MaxQueryVariants; limit > 0 && u.RawQuery != "" {
+		if _, ok := variants[queryHash]; !ok && len(variants) >= limit {
+			return d.trap(req, "too many query variants", pathKey+"?*")
+		}
+	}
+
+	if d.config.MaxUrlsPerTemplate > 0 {
+		addHash(d.templates, queryTemplate, urlHash)
+	}
+	if d.config.MaxQueryVariants > 0 && u.RawQuery != "" {
+		addHash(d.queries, pathKey, queryHash)
+	}
+	return true, ""
+}
+
+// addHash adds hash to the set of key, forgetting another key if sets is full
+func addHash(sets map[string]hashSet, key string, hash uint64) {
+	set, ok := sets[key]
+	if !ok {
+		forgetOne(sets, maxTrapKeys)
+		set = make(hashSet)
+		sets[key] = set
+	}
+	set[hash] = struct{}{}
+}
+
+// forgetOne deletes an arbitrary entry of m when it holds limit entries
+func forgetOne[V any](m map[string]V, limit int) {
+	if len(m) < limit {
+		return
+	}
+	for key := range m {
+		delete(m, key)
+		return
+	}
+}
+
+func (d *trapDetector) trap(req *Request, heuristic string, pattern string) (bool, string) {
+	key := heuristic + " " + pattern
+	report, ok := d.reports[key]
+	if !ok {
+		forgetOne(d.reports, maxTrapKeys)
+		report = &TrapReport{Heuristic: heuristic, Pattern: pattern}
+		d.reports[key] = report
+	}
+	report.Count++
+	if len(report.Samples) < trapSamples {
+		report.Samples = append(report.Samples, req.Url.String())
+	}
+	return false, "trap: " + heuristic
+}
+
+// pathSegments returns the non-empty segments of p
+func pathSegments(p string) []string {
+	var segments []string
+	for _, segment := range strings.Split(p, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// pathTemplate replaces numbers, dates and IDs in the path of u with placeholders
+func pathTemplate(u *url.URL, segments []string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(u.Host))
+	for _, segment := range segments {
+		b.WriteString("/")
+		switch {
+		case numberSegment.MatchString(segment):
+			b.WriteString("{n}")
+		case dateSegment.MatchString(segment):
+			b.WriteString("{date}")
+		case idSegment.MatchString(segment):
+			b.WriteString("{id}")
+		default:
+			b.WriteString(segment)
+		}
+	}
+	if strings.HasSuffix(u.Path, "/") && len(segments) > 0 {
+		b.WriteString("/")
+	}
+	return b.String()
+}
+
+// queryNames returns the sorted parameter names of the query of u, e.g. "?page&sort"
+func queryNames(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	names := []string{}
+	for name := range u.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "?" + strings.Join(names, "&")
+}
+
+// Traps returns the detected traps, most refused URLs first
+func (f *Frontier) Traps() []TrapReport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.traps == nil {
+		return nil
+	}
+
+	reports := make([]TrapReport, 0, len(f.traps.reports))
+	for _, report := range f.traps.reports {
+		copied := *report
+		copied.Samples = append([]string{}, report.Samples...)
+		reports = append(reports, copied)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Count != reports[j].Count {
+			return reports[i].Count > reports[j].Count
+		}
+		return reports[i].Pattern < reports[j].Pattern
+	})
+	return reports
+}
@@ -0,0 +1,131 @@
+package frontier
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTrapDetection tests each trap heuristic on URLs discovered from a seed
+func TestTrapDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TrapConfig
+		urls     []string
+		accepted int
+		reason   string
+	}{
+		{
+			"Long URL",
+			TrapConfig{MaxUrlLength: 40},
+			[]string{"https://example.com/short", "https://example.com/" + strings.Repeat("x", 40)},
+			1, "trap: url too long",
+		},
+		{
+			"Deep path",
+			TrapConfig{MaxPathDepth: 3},
+			[]string{"https://example.com/a/b/c", "https://example.com/a/b/c/d"},
+			1, "trap: path too deep",
+		},
+		{
+			"Repeated segments",
+			TrapConfig{MaxRepeatedSegments: 2},
+			[]string{"https://example.com/a/b/a/b", "https://example.com/a/b/a/b/a/b"},
+			1, "trap: repeated path segment",
+		},
+		{
+			"Calendar",
+			TrapConfig{MaxUrlsPerTemplate: 3},
+			[]string{
+				"https://example.com/calendar/2024/01", "https://example.com/calendar/2024/02",
+				"https://example.com/calendar/2024/03", "https://example.com/calendar/2024/04",
+				"https://example.com/calendar/about",
+			},
+			4, "trap: too many urls per template",
+		},
+		{
+			"Faceted search",
+			TrapConfig{MaxQueryVariants: 2},
+			[]string{
+				"https://example.com/search?color=red", "https://example.com/search?size=m",
+				"https://example.com/search?color=red&size=m", "https://example.com/other?color=red",
+			},
+			3, "trap: too many query variants",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFrontier([]url.URL{}, []string{}, WithTrapDetection(tt.config))
+			defer f.Terminate()
+			seedURL, _ := url.Parse("https://example.com/")
+			seed := NewRequest(seedURL)
+
+			accepted := 0
+			for _, s := range tt.urls {
+				u, _ := url.Parse(s)
+				if f.Add(seed.Child(u)) {
+					accepted++
+				}
+			}
+			if accepted != tt.accepted {
+				t.Errorf("Expected %d accepted URLs, got %d", tt.accepted, accepted)
+			}
+			if count := f.Rejected()[tt.reason]; count != len(tt.urls)-tt.accepted {
+				t.Errorf("Expected %d rejections for %q, got %v", len(tt.urls)-tt.accepted, tt.reason, f.Rejected())
+			}
+		})
+	}
+}
+
+// TestTrapRevisits tests that revisits of a URL do not count against its template again
+func TestTrapRevisits(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{},
+		WithRevisitPolicy(RevisitPolicy{Default: time.Millisecond}),
+		WithTrapDetection(TrapConfig{MaxUrlsPerTemplate: 2}))
+	defer f.Terminate()
+	seedURL, _ := url.Parse("https://shop.com/")
+	seed := NewRequest(seedURL)
+
+	product, _ := url.Parse("https://shop.com/product/1")
+	for i := range 5 {
+		if !f.Add(seed.Child(product)) {
+			t.Fatalf("Revisit %d refused: %v", i+1, f.Rejected())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, s := range []string{"https://shop.com/product/2", "https://shop.com/product/3"} {
+		u, _ := url.Parse(s)
+		f.Add(seed.Child(u))
+	}
+	if count := f.Rejected()["trap: too many urls per template"]; count != 1 {
+		t.Errorf("Expected only the third distinct product to be refused, got %v", f.Rejected())
+	}
+}
+
+// TestTrapReports tests that traps are reported with their template and sample URLs
+func TestTrapReports(t *testing.T) {
+	f := NewFrontier([]url.URL{}, []string{}, WithTrapDetection(TrapConfig{MaxUrlsPerTemplate: 2}))
+	defer f.Terminate()
+	seedURL, _ := url.Parse("https://example.com/")
+	seed := NewRequest(seedURL)
+
+	for day := 1; day <= 10; day++ {
+		u, _ := url.Parse(fmt.Sprintf("https://example.com/events/2024-05-%02d?view=day", day))
+		f.Add(seed.Child(u))
+	}
+
+	reports := f.Traps()
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 trap report, got %v", reports)
+	}
+	report := reports[0]
+	if report.Pattern != "example.com/events/{date}?view" || report.Count != 8 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if len(report.Samples) != trapSamples || report.Samples[0] != "https://example.com/events/2024-05-03?view=day" {
+		t.Errorf("Unexpected samples: %v", report.Samples)
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/Fardin-E/web_crawler.git/canonical"
 	"github.com/Fardin-E/web_crawler.git/crawler"
+	"github.com/Fardin-E/web_crawler.git/frontier"
 	"github.com/Fardin-E/web_crawler.git/storage"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -22,18 +29,78 @@ var (
 
 	// Crawl command flags
 	urls            []string
+	seedsFile       string
+	seedsFormat     string
 	depth           int
 	workers         int
 	outputDir       string
 	excludePatterns []string
+	includePatterns []string
+	scope           string
 	revisitDelay    time.Duration
+	revisitRules    []string
 	maxRedirects    int
+	ignoreRobots    bool
+	politeness      time.Duration
+	maxHostConns    int
+	maxCrawlDelay   time.Duration
+	hostPolicies    []string
+	throttleMin     time.Duration
+	throttleMax     time.Duration
+	sitemaps        []string
+	discoverMaps    bool
+	stripParams     []string
+	maxPages        int
+	maxBytes        int64
+	maxDuration     time.Duration
+	maxHostPages    int
+	scores          []string
+	scorePatterns   []string
+	stateDir        string
+	resumeDir       string
+	seenCapacity    uint64
+	seenFPRate      float64
+	seenRecent      int
+	trapConfig      frontier.TrapConfig
+	watch           bool
+	recrawlMin      time.Duration
+	recrawlMax      time.Duration
+	retries         int
+	retryBaseDelay  time.Duration
+	retryMaxDelay   time.Duration
+	retryStatuses   []int
+	retryErrors     []string
+	deadLetterPath  string
+	conditional     bool
+	validatorsPath  string
+	userAgent       string
+	contact         string
+	headers         []string
+	httpConfig      crawler.HTTPConfig
+	statsInterval   time.Duration
+	statsAddr       string
 
 	// Serve command flags
-	port int
-	host string
+	port          int
+	host          string
+	serveStateDir string
+
+	// Frontier command flags
+	inspectJSON bool
+	inspectTop  int
+
+	// Dead-letter command flags
+	deadLetterJSON bool
+	replayStateDir string
+	replayStrip    []string
 )
 
+// deadLetterFile is the dead-letter file kept in the state or output directory
+const deadLetterFile = "deadletter.jsonl"
+
+// validatorFile is the validator file kept next to the stored pages
+const validatorFile = "validators.jsonl"
+
 // MAIN ENTRY POINT
 
 func main() {
@@ -52,6 +119,8 @@ func main() {
 	// Add subcommands
 	rootCmd.AddCommand(crawlCmd())
 	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(frontierCmd())
+	rootCmd.AddCommand(deadLetterCmd())
 	rootCmd.AddCommand(versionCmd())
 
 	// Execute
@@ -78,21 +147,112 @@ Examples:
 
   # Crawl with exclude patterns
   crawler crawl --url https://example.com --exclude /login --exclude /admin
+
+  # Stay on the seed's registered domain and only follow documentation pages
+  crawler crawl --url https://www.example.com/docs/ --scope same-domain --include "/docs/*"
+
+  # Exclude with a regular expression over the full URL
+  crawler crawl --url https://example.com --exclude 're:\?(sort|filter)='
+
+  # Seed from the site's sitemaps as well as the start page
+  crawler crawl --url https://example.com --discover-sitemaps
+
+  # Seed from a list of sites, with per-seed depth and scope columns
+  crawler crawl --seeds-file seeds.csv
+  cat urls.txt | crawler crawl --seeds-file -
+
+  # Seed only from a sitemap
+  crawler crawl --sitemap https://example.com/sitemap_index.xml
+
+  # Crawl shallow pages and documentation first
+  crawler crawl --url https://example.com --score depth=1 --score inlinks=0.5 --score-pattern "/docs/*=2"
+
+  # Fetch up to 4 pages at once from the docs hosts, waiting 500ms between requests
+  crawler crawl --url https://docs.example.com --host-policy "*.example.com:delay=500ms,connections=4"
+
+  # Stop after 1000 pages or 30 minutes, with at most 100 pages per host
+  crawler crawl --url https://example.com --max-pages 1000 --max-duration 30m --max-pages-per-host 100
+
+  # Keep the frontier on disk, then resume the crawl after it was stopped
+  crawler crawl --url https://example.com --state-dir ./state
+  crawler crawl --resume ./state
+
+  # Monitor a site, revisiting pages between every 10 minutes and once a week
+  # depending on how often they change
+  crawler crawl --url https://example.com --watch --recrawl-min 10m --recrawl-max 168h
+
+  # Identify the crawl, give slow servers more time and send a cookie
+  crawler crawl --url https://example.com --contact https://example.org/crawler --timeout 1m --header "Cookie: consent=yes"
+
+  # Try failed URLs up to 5 times, also retrying 404s and DNS failures
+  crawler crawl --url https://example.com --retries 5 --retry-status 404,429,500,502,503,504 --retry-errors timeout,connection,dns
+
+  # Remember up to 50 million URLs in a Bloom filter instead of an exact set
+  crawler crawl --url https://example.com --seen-filter-capacity 50000000
 `,
 		RunE: runCrawl,
 	}
 
 	// Add flags specific to crawl command
-	cmd.Flags().StringSliceVarP(&urls, "url", "u", []string{}, "URL(s) to crawl (required, can be specified multiple times)")
-	cmd.Flags().IntVarP(&depth, "depth", "d", 3, "Maximum crawl depth")
+	cmd.Flags().StringSliceVarP(&urls, "url", "u", []string{}, "URL(s) to crawl (can be specified multiple times)")
+	cmd.Flags().StringVar(&seedsFile, "seeds-file", "", "File of seed URLs, one per line, as CSV (url,depth,scope) or as JSON lines; - reads stdin")
+	cmd.Flags().StringVar(&seedsFormat, "seeds-format", "", "Format of --seeds-file: txt, csv or jsonl (detected from the extension or first line if empty)")
+	cmd.Flags().IntVarP(&depth, "depth", "d", 3, "Maximum crawl depth (0 for unlimited)")
 	cmd.Flags().IntVarP(&workers, "workers", "w", 10, "Number of concurrent workers")
 	cmd.Flags().StringVarP(&outputDir, "output", "o", "./data", "Output directory for crawled data")
-	cmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "e", []string{}, "URL patterns to exclude (can be specified multiple times)")
-	cmd.Flags().DurationVar(&revisitDelay, "revisit-delay", 2*time.Hour, "Delay before revisiting a URL")
-	cmd.Flags().IntVar(&maxRedirects, "max-redirects", 5, "Maximum number of redirects to follow")
-
-	// Mark required flags
-	cmd.MarkFlagRequired("url")
+	cmd.Flags().StringSliceVarP(&excludePatterns, "exclude", "e", []string{}, "URL patterns to exclude: host glob, /path glob, full URL glob or re:<regexp> (can be specified multiple times)")
+	cmd.Flags().StringSliceVar(&includePatterns, "include", []string{}, "URL patterns discovered links must match, same syntax as --exclude (can be specified multiple times)")
+	cmd.Flags().StringVar(&scope, "scope", string(frontier.ScopeAny), "Built-in scope for discovered links: any, same-host, same-domain or seed-path")
+	cmd.Flags().DurationVar(&revisitDelay, "revisit-delay", frontier.DefaultRevisitDelay, "Delay before revisiting a URL")
+	cmd.Flags().StringSliceVar(&revisitRules, "revisit-rule", []string{}, "Per host/path revisit delay as host/path=duration, e.g. example.com/news/*=1h (can be specified multiple times)")
+	cmd.Flags().IntVar(&maxRedirects, "max-redirects", 5, "Maximum number of redirects to follow (0 to not follow any)")
+	cmd.Flags().StringSliceVar(&stripParams, "strip-param", canonical.DefaultStripParams, "Query parameter name globs removed from discovered URLs (can be specified multiple times)")
+	cmd.Flags().BoolVar(&ignoreRobots, "ignore-robots", false, "Do not fetch or obey robots.txt")
+	cmd.Flags().StringSliceVar(&sitemaps, "sitemap", []string{}, "Sitemap or sitemap index URL(s) to seed from, may be gzipped (can be specified multiple times)")
+	cmd.Flags().BoolVar(&discoverMaps, "discover-sitemaps", false, "Seed from sitemaps listed in each seed host's robots.txt or at /sitemap.xml")
+	cmd.Flags().DurationVar(&politeness, "politeness-delay", crawler.DefaultPolitenessDelay, "Minimum delay between requests to the same host (robots.txt Crawl-delay wins if longer)")
+	cmd.Flags().IntVar(&maxHostConns, "max-connections-per-host", 1, "Maximum concurrent requests to the same host")
+	cmd.Flags().DurationVar(&maxCrawlDelay, "max-crawl-delay", 0, "Longest robots.txt Crawl-delay to obey (0 for unlimited)")
+	cmd.Flags().StringArrayVar(&hostPolicies, "host-policy", []string{}, "Per-host politeness as host:key=value,... with keys delay, connections and max-crawl-delay, e.g. *.example.com:delay=500ms,connections=4 (can be specified multiple times)")
+	cmd.Flags().DurationVar(&throttleMin, "throttle-min-delay", 0, "Shortest delay hosts that respond quickly speed up to (0 for the politeness delay)")
+	cmd.Flags().DurationVar(&throttleMax, "throttle-max-delay", time.Minute, "Longest delay hosts that respond slowly or with 429/503 are slowed down to (0 disables throttling)")
+	cmd.Flags().IntVar(&maxPages, "max-pages", 0, "Stop after fetching this many pages (0 for unlimited)")
+	cmd.Flags().Int64Var(&maxBytes, "max-bytes", 0, "Stop after downloading this many bytes (0 for unlimited)")
+	cmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "Stop after crawling for this long (0 for unlimited)")
+	cmd.Flags().IntVar(&maxHostPages, "max-pages-per-host", 0, "Maximum pages queued per host (0 for unlimited)")
+	cmd.Flags().StringSliceVar(&scores, "score", []string{}, "Crawl higher scoring URLs first, as name=weight with name one of depth, sitemap, freshness, inlinks (can be specified multiple times)")
+	cmd.Flags().StringVar(&stateDir, "state-dir", "", "Directory to keep the frontier in so the crawl can be resumed")
+	cmd.Flags().StringVar(&resumeDir, "resume", "", "Resume the crawl whose frontier is kept in this directory")
+	cmd.Flags().Uint64Var(&seenCapacity, "seen-filter-capacity", 0, "Remember seen URLs in a Bloom filter sized for this many URLs instead of an exact set (0 to disable)")
+	cmd.Flags().Float64Var(&seenFPRate, "seen-filter-fp-rate", frontier.DefaultFalsePositiveRate, "False positive rate of the seen URL Bloom filter")
+	cmd.Flags().IntVar(&seenRecent, "seen-recent", 100000, "Number of recently seen URLs kept exactly alongside the Bloom filter")
+	cmd.Flags().IntVar(&trapConfig.MaxUrlLength, "trap-max-url-length", 2048, "Refuse discovered URLs longer than this (0 to disable)")
+	cmd.Flags().IntVar(&trapConfig.MaxPathDepth, "trap-max-path-depth", 32, "Refuse discovered URLs with more path segments than this (0 to disable)")
+	cmd.Flags().IntVar(&trapConfig.MaxRepeatedSegments, "trap-max-repeated-segments", 3, "Refuse discovered URLs repeating a path segment more often than this (0 to disable)")
+	cmd.Flags().IntVar(&trapConfig.MaxUrlsPerTemplate, "trap-max-urls-per-template", 1000, "Maximum URLs per host and path template, with numbers and IDs as wildcards (0 to disable)")
+	cmd.Flags().IntVar(&trapConfig.MaxQueryVariants, "trap-max-query-variants", 1000, "Maximum distinct queries per host and path (0 to disable)")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Keep running and revisit pages as often as they change, until interrupted or a budget is exhausted")
+	cmd.Flags().DurationVar(&recrawlMin, "recrawl-min", frontier.DefaultMinRecrawlInterval, "Shortest revisit interval in watch mode")
+	cmd.Flags().DurationVar(&recrawlMax, "recrawl-max", frontier.DefaultMaxRecrawlInterval, "Longest revisit interval in watch mode")
+	cmd.Flags().IntVar(&retries, "retries", 3, "Maximum attempts to fetch a URL before it is dead-lettered (1 disables retries)")
+	cmd.Flags().DurationVar(&retryBaseDelay, "retry-base-delay", crawler.DefaultRetryBaseDelay, "Delay before the first retry, doubled for every further retry")
+	cmd.Flags().DurationVar(&retryMaxDelay, "retry-max-delay", crawler.DefaultRetryMaxDelay, "Longest delay between retries, unless Retry-After asks for more")
+	cmd.Flags().IntSliceVar(&retryStatuses, "retry-status", crawler.DefaultRetryStatuses, "Response status codes that are retried")
+	cmd.Flags().StringSliceVar(&retryErrors, "retry-errors", []string{string(crawler.ErrorTimeout), string(crawler.ErrorConnection)}, "Error classes that are retried: timeout, connection, dns or other")
+	cmd.Flags().StringVar(&deadLetterPath, "dead-letter", "", "File recording URLs that failed for good (default deadletter.jsonl in --state-dir, or else in --output)")
+	cmd.Flags().BoolVar(&conditional, "conditional", true, "Send the ETag and Last-Modified of the last fetch so unchanged pages are not downloaded again")
+	cmd.Flags().StringVar(&validatorsPath, "validators", "", "File keeping the ETag and Last-Modified of fetched pages (default validators.jsonl in --output)")
+	cmd.Flags().StringVar(&userAgent, "user-agent", crawler.DefaultUserAgent, "User agent sent with every request and matched against robots.txt")
+	cmd.Flags().StringVar(&contact, "contact", "", "URL or email address added to the user agent so site owners can reach you")
+	cmd.Flags().StringArrayVar(&headers, "header", []string{}, "Header sent with every request, as \"Name: value\" (can be specified multiple times)")
+	cmd.Flags().DurationVar(&httpConfig.Timeout, "timeout", crawler.DefaultTimeout, "Longest time a fetch may take, reading the body included")
+	cmd.Flags().DurationVar(&httpConfig.ConnectTimeout, "connect-timeout", crawler.DefaultConnectTimeout, "Longest time to establish a connection")
+	cmd.Flags().DurationVar(&httpConfig.TLSHandshakeTimeout, "tls-handshake-timeout", crawler.DefaultTLSHandshakeTimeout, "Longest time for the TLS handshake")
+	cmd.Flags().DurationVar(&httpConfig.ResponseHeaderTimeout, "response-header-timeout", crawler.DefaultResponseHeaderTimeout, "Longest wait for response headers after sending a request")
+	cmd.Flags().IntVar(&httpConfig.MaxIdleConnsPerHost, "max-idle-conns-per-host", crawler.DefaultMaxIdleConnsPerHost, "Keep-alive connections kept open per host between fetches")
+	cmd.Flags().StringVar(&statsAddr, "stats-addr", "", "Address to serve live frontier and host statistics on during the crawl, e.g. localhost:8081")
+	cmd.Flags().DurationVar(&statsInterval, "stats-interval", time.Minute, "How often the frontier and the hosts pushing back are logged during the crawl (0 to only log them at the end)")
+	cmd.Flags().StringSliceVar(&scorePatterns, "score-pattern", []string{}, "Add weight to URLs matching a pattern, as pattern=weight with the --exclude pattern syntax (can be specified multiple times)")
 
 	return cmd
 }
@@ -109,6 +269,33 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 		ForceColors:   true,
 	})
 
+	if resumeDir != "" {
+		if stateDir != "" && stateDir != resumeDir {
+			return fmt.Errorf("--state-dir and --resume must not name different directories")
+		}
+		if _, err := os.Stat(filepath.Join(resumeDir, frontier.HistoryFile)); err != nil {
+			return fmt.Errorf("no crawl to resume in %s: %w", resumeDir, err)
+		}
+		stateDir = resumeDir
+	}
+
+	// Load seeds from a file or stdin
+	var seeds []*frontier.Request
+	if seedsFile != "" {
+		loaded, invalid, err := loadSeeds(seedsFile, seedsFormat, cmd.InOrStdin())
+		if err != nil {
+			return err
+		}
+		seeds = loaded
+		for _, err := range invalid {
+			log.Warnf("Skipping seed: %s", err)
+		}
+		log.Infof("Loaded %d seeds from %s, skipped %d invalid lines", len(seeds), seedsFile, len(invalid))
+	}
+	if resumeDir == "" && len(urls) == 0 && len(seeds) == 0 && len(sitemaps) == 0 {
+		return fmt.Errorf("at least one --url, --seeds-file seed or --sitemap is required")
+	}
+
 	log.Info("Starting web crawler...")
 	log.Infof("URLs: %v", urls)
 	log.Infof("Workers: %d", workers)
@@ -121,28 +308,228 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("invalid URL '%s' : %w", urlStr, err)
 		}
+		if (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+			return fmt.Errorf("invalid URL '%s': expected an absolute http or https URL", urlStr)
+		}
 		initialUrls = append(initialUrls, *parsedUrl)
 	}
 
+	// Validate scope rules
+	scopeKind, err := frontier.ParseScopeKind(scope)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range append(append([]string{}, excludePatterns...), includePatterns...) {
+		if _, err := frontier.ParseRule(pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	// Parse revisit rules
+	parsedRevisitRules := []frontier.RevisitRule{}
+	for _, rule := range revisitRules {
+		parsedRule, err := frontier.ParseRevisitRule(rule)
+		if err != nil {
+			return fmt.Errorf("invalid revisit rule: %w", err)
+		}
+		parsedRevisitRules = append(parsedRevisitRules, parsedRule)
+	}
+
+	// Parse host policies
+	parsedHostPolicies := []crawler.HostPolicy{}
+	for _, spec := range hostPolicies {
+		policy, err := crawler.ParseHostPolicy(spec)
+		if err != nil {
+			return fmt.Errorf("invalid host policy: %w", err)
+		}
+		parsedHostPolicies = append(parsedHostPolicies, policy)
+	}
+
+	if watch && recrawlMin > recrawlMax {
+		return fmt.Errorf("--recrawl-min must not be longer than --recrawl-max")
+	}
+
+	// Parse extra request headers
+	httpConfig.Headers = http.Header{}
+	for _, spec := range headers {
+		name, value, err := crawler.ParseHeader(spec)
+		if err != nil {
+			return fmt.Errorf("invalid --header: %w", err)
+		}
+		httpConfig.Headers.Add(name, value)
+	}
+
+	// Parse the retry policy
+	if retryBaseDelay > retryMaxDelay {
+		return fmt.Errorf("--retry-base-delay must not be longer than --retry-max-delay")
+	}
+	retryClasses := []crawler.ErrorClass{}
+	for _, name := range retryErrors {
+		class, err := crawler.ParseErrorClass(name)
+		if err != nil {
+			return fmt.Errorf("invalid --retry-errors: %w", err)
+		}
+		retryClasses = append(retryClasses, class)
+	}
+
+	// Build the frontier scorer
+	var scorer frontier.Scorer
+	if len(scores) > 0 || len(scorePatterns) > 0 {
+		composite := frontier.CompositeScorer{}
+		for _, spec := range scores {
+			weighted, err := frontier.ParseWeightedScorer(spec, canonical.New(stripParams))
+			if err != nil {
+				return fmt.Errorf("invalid score: %w", err)
+			}
+			composite = append(composite, weighted)
+		}
+		patterns := frontier.PatternScorer{}
+		for _, spec := range scorePatterns {
+			pattern, err := frontier.ParsePatternWeight(spec)
+			if err != nil {
+				return fmt.Errorf("invalid score pattern: %w", err)
+			}
+			patterns.Patterns = append(patterns.Patterns, pattern)
+		}
+		if len(patterns.Patterns) > 0 {
+			composite = append(composite, frontier.WeightedScorer{Scorer: patterns, Weight: 1})
+		}
+		scorer = composite
+	}
+
+	// Open the persistent frontier
+	if seenCapacity > 0 && stateDir != "" {
+		return fmt.Errorf("--seen-filter-capacity cannot be combined with --state-dir or --resume")
+	}
+	var queue frontier.Queue
+	var history frontier.History
+	handedOver := false
+	if stateDir != "" {
+		diskQueue, diskHistory, err := frontier.OpenDir(stateDir)
+		if err != nil {
+			return fmt.Errorf("failed to open frontier: %w", err)
+		}
+		queue, history = diskQueue, diskHistory
+		log.Infof("Frontier state: %s (%d queued URLs)", stateDir, queue.Len())
+		// The crawler closes the state when it stops, until it is handed
+		// over it is closed here if the crawl cannot start
+		defer func() {
+			if !handedOver {
+				diskQueue.Close()
+				diskHistory.Close()
+			}
+		}()
+	}
+	// A watching crawl keeps its revisit schedule with the frontier state
+	var recrawlFile string
+	if watch && stateDir != "" {
+		recrawlFile = filepath.Join(stateDir, frontier.RecrawlFile)
+	}
+	var bloomHistory *frontier.BloomHistory
+	if seenCapacity > 0 {
+		bloomHistory = frontier.NewBloomHistory(seenCapacity, seenFPRate, seenRecent, revisitDelay)
+		history = bloomHistory
+	}
+
 	// Create storage
 	contentStorage, err := storage.NewFileStorage(outputDir)
 	if err != nil {
 		return fmt.Errorf("failed to create storage: %w", err)
 	}
 
+	// Open the dead-letter file
+	if deadLetterPath == "" {
+		deadLetterPath = filepath.Join(outputDir, deadLetterFile)
+		if stateDir != "" {
+			deadLetterPath = filepath.Join(stateDir, deadLetterFile)
+		}
+	}
+	deadLetters, err := crawler.OpenDeadLetterFile(deadLetterPath)
+	if err != nil {
+		return err
+	}
+	defer deadLetters.Close()
+
+	// Open the validator file, it must stay with the stored pages: links of
+	// unchanged pages are read from their stored copy
+	var validators crawler.ValidatorStore
+	if conditional {
+		if validatorsPath == "" {
+			validatorsPath = filepath.Join(outputDir, validatorFile)
+		}
+		file, err := crawler.OpenValidatorFile(validatorsPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		log.Infof("Validators: %s (%d pages)", validatorsPath, file.Len())
+		validators = file
+	}
+
+	// Config.MaxRedirects uses the default when zero
+	redirectLimit := maxRedirects
+	if redirectLimit == 0 {
+		redirectLimit = -1
+	}
+
 	// Create crawler config
 	crawlerConfig := &crawler.Config{
-		MaxRedirects:    maxRedirects,
-		RevisitDelay:    revisitDelay,
-		WorkerCount:     workers,
-		ExcludePatterns: excludePatterns,
+		Seeds:                 seeds,
+		MaxDepth:              depth,
+		MaxRedirects:          redirectLimit,
+		RevisitDelay:          revisitDelay,
+		RevisitRules:          parsedRevisitRules,
+		WorkerCount:           workers,
+		ExcludePatterns:       excludePatterns,
+		IncludePatterns:       includePatterns,
+		Scope:                 scopeKind,
+		IgnoreRobots:          ignoreRobots,
+		PolitenessDelay:       politeness,
+		Sitemaps:              sitemaps,
+		DiscoverSitemaps:      discoverMaps,
+		StripParams:           stripParams,
+		MaxPages:              maxPages,
+		MaxBytes:              maxBytes,
+		MaxDuration:           maxDuration,
+		MaxPagesPerHost:       maxHostPages,
+		Scorer:                scorer,
+		Queue:                 queue,
+		History:               history,
+		Traps:                 trapConfig,
+		Watch:                 watch,
+		RecrawlFile:           recrawlFile,
+		MaxConnectionsPerHost: maxHostConns,
+		MaxCrawlDelay:         maxCrawlDelay,
+		HostPolicies:          parsedHostPolicies,
+		Throttle:              crawler.ThrottleConfig{MinDelay: throttleMin, MaxDelay: throttleMax},
+		Recrawl:               frontier.RecrawlPolicy{MinInterval: recrawlMin, MaxInterval: recrawlMax},
+		Retry: crawler.RetryPolicy{
+			MaxAttempts: retries,
+			BaseDelay:   retryBaseDelay,
+			MaxDelay:    retryMaxDelay,
+			Statuses:    retryStatuses,
+			Classes:     retryClasses,
+		},
+		DeadLetters:   deadLetters,
+		Validators:    validators,
+		Unconditional: !conditional,
+		UserAgent:     userAgent,
+		Contact:       contact,
+		HTTP:          httpConfig,
+	}
+
+	if verbose {
+		crawlerConfig.Middleware = append(crawlerConfig.Middleware, crawler.LogFetches())
 	}
 
 	// Create crawler
 	c := crawler.NewCrawler(initialUrls, contentStorage, crawlerConfig)
+	handedOver = true
 
 	// Add custom processors
 	c.AddProcessor(&LoggerProcessor{})
+	statuses := &StatusCounter{}
+	c.AddProcessor(statuses)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -154,6 +541,13 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 		c.Start()
 		close(done)
 	}()
+	if statsInterval > 0 {
+		go logStatsEvery(c, statsInterval, done)
+	}
+	if statsAddr != "" {
+		server := statsServer(c, statsAddr)
+		defer server.Close()
+	}
 
 	// Wait for completion or interrupt
 	select {
@@ -165,10 +559,89 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 	case <-done:
 		log.Info("Crawl completed")
 	}
+	log.Infof("Stop reason: %s", c.StopReason())
+	stats := c.FrontierStats()
+	log.Infof("Frontier: %d queued, %d seen", stats.Queued, stats.Seen)
+	for _, count := range statuses.Counts() {
+		log.Infof("Status %d: %d pages", count.Status, count.Count)
+	}
+
+	logPushedBack(c)
+
+	if letters, err := crawler.ReadDeadLetters(deadLetterPath); err == nil && len(letters) > 0 {
+		log.Warnf("%d URLs in %s failed for good, list them with: crawler deadletter list %s", len(letters), deadLetterPath, deadLetterPath)
+	}
+
+	for _, trap := range c.Traps() {
+		log.Warnf("Crawler trap %s", trap)
+	}
+
+	if bloomHistory != nil {
+		stats := bloomHistory.Stats()
+		log.Infof("Seen filter: %d recent, %d windows, fullest %s", stats.Recent, stats.Filters, stats.Fullest)
+		if stats.Fullest.FillRatio > 0.5 {
+			log.Warn("Seen filter is over half full, raise --seen-filter-capacity to keep false positives low")
+		}
+	}
+
+	for reason, count := range c.Rejected() {
+		log.WithField("reason", reason).Infof("Rejected %d URLs", count)
+	}
 
 	return nil
 }
 
+// logStatsEvery logs the frontier and the hosts pushing back every interval
+// until done is closed
+func logStatsEvery(c *crawler.Crawler, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			stats := c.FrontierStats()
+			log.Infof("Frontier: %d queued, %d in flight, %d seen", stats.Queued, stats.InFlight, stats.Seen)
+			logPushedBack(c)
+		case <-done:
+			return
+		}
+	}
+}
+
+// logPushedBack logs the hosts fetched more slowly than their politeness
+// delay, or that answered with 429 or 503
+func logPushedBack(c *crawler.Crawler) {
+	for _, host := range c.HostStats() {
+		if host.Delay <= host.BaseDelay && host.Throttled == 0 {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"delay":     host.Delay,
+			"base":      host.BaseDelay,
+			"latency":   host.Latency,
+			"throttled": host.Throttled,
+			"queued":    host.Queued,
+		}).Warnf("Host %s pushed back", host.Host)
+	}
+}
+
+// statsServer serves the live frontier and host statistics of c on addr
+func statsServer(c *crawler.Crawler, addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/frontier", frontierHandler(func() (frontier.Stats, error) {
+		return c.FrontierStats(), nil
+	}))
+	mux.HandleFunc("/api/v1/hosts", hostsHandler(c))
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Failed to serve statistics: %s", err)
+		}
+	}()
+	log.Infof("Serving statistics at http://%s/api/v1/frontier and /api/v1/hosts", addr)
+	return server
+}
+
 // SERVE COMMAND
 
 func serveCmd() *cobra.Command {
@@ -186,12 +659,19 @@ Examples:
   
   # Start on specific host and port
   crawler serve --host 0.0.0.0 --port 8080 
+
+  # Also report on the frontier of a crawl started with --state-dir
+  crawler serve --state-dir ./state
+
+Rejections and in-flight requests are only known to the running crawl,
+serve them with: crawler crawl --stats-addr localhost:8081
 `,
 		RunE: runServe,
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to listen on")
 	cmd.Flags().StringVar(&host, "host", "localHost", "Host to bind to")
+	cmd.Flags().StringVar(&serveStateDir, "state-dir", "", "Frontier state directory to report on at /api/v1/frontier")
 
 	return cmd
 }
@@ -207,11 +687,19 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Setup routes
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/api/v1/crawl", crawlJobHandler)
+	if serveStateDir != "" {
+		http.HandleFunc("/api/v1/frontier", frontierHandler(func() (frontier.Stats, error) {
+			return frontier.InspectDir(serveStateDir)
+		}))
+	}
 
 	log.Infof("API server running at http://%s", address)
 	log.Info("Endpoints:")
-	log.Info("  GET   /health         - Health check")
-	log.Info("  POST  /api/v1/crawl   - Start crawl job")
+	log.Info("  GET   /health           - Health check")
+	log.Info("  POST  /api/v1/crawl     - Start crawl job")
+	if serveStateDir != "" {
+		log.Info("  GET   /api/v1/frontier  - Frontier statistics")
+	}
 
 	return http.ListenAndServe(address, nil)
 }
@@ -234,6 +722,272 @@ func crawlJobHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"job_id":"123","status":"queued"}`))
 }
 
+// frontierHandler reports the frontier stats returned by inspect, read from
+// a state directory or live from a running crawl
+func frontierHandler(inspect func() (frontier.Stats, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		stats, err := inspect()
+		if err != nil {
+			log.Errorf("Failed to inspect frontier: %s", err)
+			http.Error(w, "Failed to inspect frontier", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// hostsHandler reports the delay, queue and response statistics of the
+// hosts c is fetching from
+func hostsHandler(c *crawler.Crawler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		hosts := c.HostStats()
+		if hosts == nil {
+			hosts = []crawler.HostStats{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hosts)
+	}
+}
+
+// FRONTIER COMMAND
+
+func frontierCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "frontier",
+		Short: "Work with a persistent frontier",
+	}
+
+	inspectCmd := &cobra.Command{
+		Use:   "inspect <state-dir>",
+		Short: "Print statistics of a frontier state directory",
+		Long: `Print the queue length, per-host queue sizes, seen count and oldest
+pending URL of a frontier kept with --state-dir. The directory is only read,
+so it can be inspected while a crawl is running.
+
+Examples:
+  crawler frontier inspect ./state
+  crawler frontier inspect ./state --json
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: runFrontierInspect,
+	}
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print the statistics as JSON")
+	inspectCmd.Flags().IntVar(&inspectTop, "top", 10, "Number of hosts to list, 0 for all")
+	cmd.AddCommand(inspectCmd)
+
+	return cmd
+}
+
+func runFrontierInspect(cmd *cobra.Command, args []string) error {
+	stats, err := frontier.InspectDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if inspectJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	fmt.Fprintf(out, "Queued: %d\n", stats.Queued)
+	fmt.Fprintf(out, "Seen:   %d\n", stats.Seen)
+	if stats.Oldest != nil {
+		fmt.Fprintf(out, "Oldest: %s (depth %d, discovered %s)\n",
+			stats.Oldest.Url, stats.Oldest.Depth, stats.Oldest.DiscoveredAt.Format(time.RFC3339))
+	}
+	if len(stats.Hosts) > 0 {
+		fmt.Fprintf(out, "Hosts:  %d\n", len(stats.Hosts))
+		for _, host := range stats.TopHosts(inspectTop) {
+			fmt.Fprintf(out, "  %8d  %s\n", host.Count, host.Host)
+		}
+	}
+	return nil
+}
+
+// DEAD-LETTER COMMAND
+
+func deadLetterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deadletter",
+		Short: "Work with the URLs that failed for good",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list <file>",
+		Short: "Print the URLs recorded in a dead-letter file",
+		Long: `Print the URLs that could not be fetched after their retries, with the
+last error, status code and number of attempts.
+
+Examples:
+  crawler deadletter list ./state/deadletter.jsonl
+  crawler deadletter list ./data/deadletter.jsonl --json
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: runDeadLetterList,
+	}
+	listCmd.Flags().BoolVar(&deadLetterJSON, "json", false, "Print the dead letters as JSON")
+	cmd.AddCommand(listCmd)
+
+	replayCmd := &cobra.Command{
+		Use:   "replay [file]",
+		Short: "Queue the URLs of a dead-letter file again",
+		Long: `Queue the URLs recorded in a dead-letter file in the frontier of a
+crawl kept with --state-dir, and empty the file. Resuming the crawl fetches
+them again, and records the ones that still fail. The file defaults to the
+dead-letter file in the state directory. The crawl must not be running.
+
+A state directory without a crawl is created, so the dead letters of a crawl
+kept in memory, found in its output directory, are replayed as a new crawl.
+
+Dead letters that cannot be queued are kept in the file. A crawl run with
+--strip-param must be replayed with the same values.
+
+Examples:
+  crawler deadletter replay --state-dir ./state
+  crawler crawl --resume ./state
+
+  crawler deadletter replay ./data/deadletter.jsonl --state-dir ./retry
+  crawler crawl --resume ./retry
+`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runDeadLetterReplay,
+	}
+	replayCmd.Flags().StringVar(&replayStateDir, "state-dir", "", "Frontier state directory to queue the URLs in")
+	replayCmd.MarkFlagRequired("state-dir")
+	replayCmd.Flags().StringSliceVar(&replayStrip, "strip-param", canonical.DefaultStripParams, "Query parameter name globs the crawl was run with, so replayed URLs are queued under the same keys")
+	cmd.AddCommand(replayCmd)
+
+	return cmd
+}
+
+func runDeadLetterList(cmd *cobra.Command, args []string) error {
+	letters, err := crawler.ReadDeadLetters(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if deadLetterJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if letters == nil {
+			letters = []crawler.DeadLetter{}
+		}
+		return encoder.Encode(letters)
+	}
+
+	for _, letter := range letters {
+		reason := string(letter.Class)
+		if letter.Status != 0 {
+			reason = strconv.Itoa(letter.Status)
+		}
+		fmt.Fprintf(out, "%s  %-10s  %d attempts  %s\n    %s\n",
+			letter.FailedAt.Format(time.RFC3339), reason, letter.Attempts, letter.Url, letter.Error)
+	}
+	fmt.Fprintf(out, "%d dead letters\n", len(letters))
+	return nil
+}
+
+func runDeadLetterReplay(cmd *cobra.Command, args []string) error {
+	path := filepath.Join(replayStateDir, deadLetterFile)
+	if len(args) > 0 {
+		path = args[0]
+	}
+	queued, err := replayDeadLetters(path, replayStateDir, canonical.New(replayStrip))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Queued %d URLs in %s, resume the crawl with: crawler crawl --resume %s\n",
+		queued, replayStateDir, replayStateDir)
+	return nil
+}
+
+// replayDeadLetters queues the dead letters of the file at path in the
+// frontier kept in stateDir, under the keys canon gives them, and returns how
+// many URLs were queued. The file is emptied only once every letter was
+// queued, letters that cannot be are written back to it.
+func replayDeadLetters(path string, stateDir string, canon *canonical.Canonicalizer) (int, error) {
+	letters, err := crawler.ReadDeadLetters(path)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, frontier.HistoryFile)); os.IsNotExist(err) {
+		log.Infof("No crawl state in %s, creating it for a new crawl", stateDir)
+	}
+
+	queue, history, err := frontier.OpenDir(stateDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open frontier: %w", err)
+	}
+	defer history.Close()
+
+	// The queue bypasses the seen history, which already holds the URLs
+	queued := make(map[string]bool, len(letters))
+	var skipped []crawler.DeadLetter
+	for _, letter := range letters {
+		req, err := letter.Request()
+		if err != nil {
+			log.Warnf("Skipping dead letter: %s", err)
+			skipped = append(skipped, letter)
+			continue
+		}
+		key := canon.Key(req.Url)
+		if queued[key] {
+			continue
+		}
+		if err := queue.Push(req, key, 0); err != nil {
+			queue.Close()
+			return 0, fmt.Errorf("failed to queue %s: %w", req.Url, err)
+		}
+		queued[key] = true
+	}
+	if err := queue.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close frontier: %w", err)
+	}
+
+	if len(skipped) == 0 {
+		if err := os.Truncate(path, 0); err != nil && !os.IsNotExist(err) {
+			return 0, fmt.Errorf("failed to empty dead-letter file: %w", err)
+		}
+		return len(queued), nil
+	}
+
+	// Replace the file at once, so the letters kept are never lost
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	file, err := crawler.OpenDeadLetterFile(tmp)
+	if err != nil {
+		return 0, err
+	}
+	for _, letter := range skipped {
+		if err := file.Record(letter); err != nil {
+			file.Close()
+			return 0, err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("failed to replace dead-letter file: %w", err)
+	}
+	log.Warnf("Kept %d dead letters that could not be queued in %s", len(skipped), path)
+	return len(queued), nil
+}
+
 // VERSION COMMAND
 
 func versionCmd() *cobra.Command {
@@ -252,10 +1006,59 @@ func versionCmd() *cobra.Command {
 type LoggerProcessor struct{}
 
 func (l *LoggerProcessor) Process(result *crawler.CrawlResult) error {
-	log.WithFields(log.Fields{
+	fields := log.Fields{
 		"url":          result.Url.String(),
+		"status":       result.StatusCode,
 		"content_type": result.ContentType,
 		"size":         len(result.Body),
-	}).Info("Processed page")
+		"duration":     result.Duration,
+	}
+	if len(result.Redirects) > 0 {
+		fields["final_url"] = result.FinalUrl.String()
+		fields["redirects"] = len(result.Redirects)
+	}
+	if result.NotModified {
+		log.WithFields(fields).Info("Page not modified")
+		return nil
+	}
+	if !result.Successful() {
+		log.WithFields(fields).Warn("Processed error page")
+		return nil
+	}
+	log.WithFields(fields).Info("Processed page")
+	return nil
+}
+
+// StatusCounter counts results by status code
+type StatusCounter struct {
+	mu     sync.Mutex
+	counts map[int]int
+}
+
+func (s *StatusCounter) Process(result *crawler.CrawlResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[int]int)
+	}
+	s.counts[result.StatusCode]++
 	return nil
 }
+
+// StatusCount is the number of results with a status code
+type StatusCount struct {
+	Status int
+	Count  int
+}
+
+// Counts returns the number of results per status code, lowest status first
+func (s *StatusCounter) Counts() []StatusCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make([]StatusCount, 0, len(s.counts))
+	for status, count := range s.counts {
+		counts = append(counts, StatusCount{Status: status, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Status < counts[j].Status })
+	return counts
+}
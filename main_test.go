@@ -1,7 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/Fardin-E/web_crawler.git/canonical"
+	"github.com/Fardin-E/web_crawler.git/crawler"
+	"github.com/Fardin-E/web_crawler.git/frontier"
 )
 
 // TestMain verifies that the main package compiles
@@ -9,3 +16,83 @@ func TestMain(t *testing.T) {
 	// This test just ensures main package builds
 	t.Log("Main package compiles successfully")
 }
+
+// writeDeadLetters writes letters to a dead-letter file in dir
+func writeDeadLetters(t *testing.T, dir string, letters ...crawler.DeadLetter) string {
+	path := filepath.Join(dir, "deadletter.jsonl")
+	var data []byte
+	for _, letter := range letters {
+		line, err := json.Marshal(letter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// queuedLen returns the number of requests queued in a state directory
+func queuedLen(t *testing.T, stateDir string) int {
+	queue, history, err := frontier.OpenDir(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	defer queue.Close()
+	return queue.Len()
+}
+
+// TestReplayDeadLetters tests that dead letters are queued under the crawl's
+// keys and that the file is emptied once every letter was queued
+func TestReplayDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	path := writeDeadLetters(t, dir,
+		crawler.DeadLetter{Url: "https://example.com/a?ref=home"},
+		crawler.DeadLetter{Url: "https://example.com/a?ref=feed"},
+		crawler.DeadLetter{Url: "https://example.com/b"},
+	)
+
+	queued, err := replayDeadLetters(path, stateDir, canonical.New([]string{"ref"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 2 {
+		t.Errorf("Expected the URLs differing by a stripped parameter to be queued once, got %d URLs", queued)
+	}
+	if n := queuedLen(t, stateDir); n != 2 {
+		t.Errorf("Expected 2 queued requests, got %d", n)
+	}
+	if letters, err := crawler.ReadDeadLetters(path); err != nil || len(letters) != 0 {
+		t.Errorf("Expected the file to be emptied, got %v, %v", letters, err)
+	}
+}
+
+// TestReplayDeadLettersKeepsSkipped tests that letters that cannot be
+// queued are kept in the file
+func TestReplayDeadLettersKeepsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	path := writeDeadLetters(t, dir,
+		crawler.DeadLetter{Url: "https://example.com/a"},
+		crawler.DeadLetter{Url: "://invalid"},
+	)
+
+	queued, err := replayDeadLetters(path, stateDir, canonical.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 {
+		t.Errorf("Expected 1 URL queued, got %d", queued)
+	}
+	letters, err := crawler.ReadDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Url != "://invalid" {
+		t.Errorf("Expected only the invalid letter to be kept, got %+v", letters)
+	}
+}
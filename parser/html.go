@@ -52,6 +52,30 @@ func (p *HtmlParser) Parse(content string) (Info, error) {
 					}
 				}
 
+			case "base":
+				// Only the first <base href> in a document is honored
+				for _, attr := range token.Attr {
+					if strings.ToLower(attr.Key) == "href" && info.Base == "" {
+						info.Base = strings.TrimSpace(attr.Val)
+					}
+				}
+
+			case "link":
+				rel, href := "", ""
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "rel":
+						rel = strings.ToLower(attr.Val)
+					case "href":
+						href = strings.TrimSpace(attr.Val)
+					}
+				}
+				for _, relType := range strings.Fields(rel) {
+					if relType == "canonical" && info.Canonical == "" {
+						info.Canonical = href
+					}
+				}
+
 			case "title":
 				collectingText = true
 				textBuffer.Reset()
@@ -11,6 +11,10 @@ type Info struct {
 	Description string
 	Paragraphs  []string
 	Links       []Token
+	// Base is the href of the document's <base> element, if any
+	Base string
+	// Canonical is the href of the document's <link rel="canonical">, if any
+	Canonical string
 }
 
 type Parser interface {
@@ -0,0 +1,214 @@
+package robots
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTTL is how long a fetched robots.txt is reused before it is fetched again
+const DefaultTTL = 24 * time.Hour
+
+// DefaultFailureTTL is how long the outcome of a failed fetch is reused, so
+// a transient error does not keep a host disallowed for a whole TTL
+const DefaultFailureTTL = 5 * time.Minute
+
+type entry struct {
+	// ready is closed once robots has been fetched
+	ready     chan struct{}
+	robots    *Robots
+	fetchedAt time.Time
+	// ttl is how long robots is reused, shorter if the fetch failed
+	ttl time.Duration
+	// failed is set when the fetch failed and no earlier rules were known,
+	// robots then disallows everything until the next fetch
+	failed bool
+	// previous is the expired entry's robots while it is fetched again
+	previous *Robots
+}
+
+// Cache fetches robots.txt once per host and answers questions about it.
+// It is safe for concurrent use; concurrent lookups for the same host share
+// a single fetch.
+type Cache struct {
+	client     *http.Client
+	userAgent  string
+	ttl        time.Duration
+	failureTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func NewCache(client *http.Client, userAgent string) *Cache {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Cache{
+		client:     client,
+		userAgent:  userAgent,
+		ttl:        DefaultTTL,
+		failureTTL: DefaultFailureTTL,
+		entries:    make(map[string]*entry),
+	}
+}
+
+// SetFailureTTL replaces DefaultFailureTTL for fetches made from now on
+func (c *Cache) SetFailureTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failureTTL = ttl
+}
+
+// Get returns the robots.txt rules for the host of u, fetching them if needed
+func (c *Cache) Get(u *url.URL) *Robots {
+	return c.lookup(u).robots
+}
+
+// RetryIn returns how long until robots.txt of the host of u is fetched
+// again when its last fetch failed and no earlier rules are known, so its
+// requests cannot be decided on yet. It is 0 once the rules are known and
+// fetches robots.txt if needed.
+func (c *Cache) RetryIn(u *url.URL) time.Duration {
+	e := c.lookup(u)
+	if !e.failed {
+		return 0
+	}
+	// At least a moment, the entry may just have expired
+	return max(e.ttl-time.Since(e.fetchedAt), time.Millisecond)
+}
+
+// lookup returns the fetched entry for the host of u, fetching it if needed
+func (c *Cache) lookup(u *url.URL) *entry {
+	key := u.Scheme + "://" + u.Host
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	var previous *Robots
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Since(e.fetchedAt) > e.ttl {
+				ok = false
+				// The rules of a failed fetch are no rules to fall back on
+				if !e.failed {
+					previous = e.robots
+				}
+			}
+		default:
+			// Another goroutine is fetching, wait for it below
+		}
+	}
+	if !ok {
+		e = &entry{ready: make(chan struct{}), previous: previous}
+		c.entries[key] = e
+		ttl, failureTTL := c.ttl, c.failureTTL
+		c.mu.Unlock()
+
+		robots, err := c.fetch(key)
+		e.ttl = ttl
+		if err != nil {
+			robots, e.ttl = c.failed(key, previous, err), failureTTL
+			e.failed = previous == nil
+		}
+		e.robots = robots
+		e.fetchedAt = time.Now()
+		close(e.ready)
+		return e
+	}
+	c.mu.Unlock()
+
+	<-e.ready
+	return e
+}
+
+// Allowed reports whether the cache's user agent may fetch u
+func (c *Cache) Allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return c.Get(u).Allowed(c.userAgent, path)
+}
+
+// CrawlDelay returns the crawl delay the host of u asks for, 0 if none
+func (c *Cache) CrawlDelay(u *url.URL) time.Duration {
+	return c.Get(u).CrawlDelay(c.userAgent)
+}
+
+// CachedCrawlDelay returns the crawl delay the host of u asked for when its
+// robots.txt was last fetched, even if that is longer ago than the TTL. It
+// never fetches and returns 0 for hosts not fetched yet.
+func (c *Cache) CachedCrawlDelay(u *url.URL) time.Duration {
+	c.mu.Lock()
+	e, ok := c.entries[u.Scheme+"://"+u.Host]
+	c.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	select {
+	case <-e.ready:
+		return e.robots.CrawlDelay(c.userAgent)
+	default:
+		if e.previous == nil {
+			return 0
+		}
+		return e.previous.CrawlDelay(c.userAgent)
+	}
+}
+
+// Sitemaps returns the sitemap URLs listed in the robots.txt of the host of u
+func (c *Cache) Sitemaps(u *url.URL) []string {
+	return c.Get(u).Sitemaps
+}
+
+// fetch downloads and parses robots.txt from origin. Following RFC 9309 a
+// missing file allows everything while a server error is a failure.
+func (c *Cache) fetch(origin string) (*Robots, error) {
+	return c.download(origin + "/robots.txt")
+}
+
+// failed returns the rules to use until robots.txt of origin is fetched
+// again after err: the previously fetched ones if any, otherwise nothing is
+// allowed, as RFC 9309 asks when robots.txt is unreachable
+func (c *Cache) failed(origin string, previous *Robots, err error) *Robots {
+	logger := log.WithField("url", origin+"/robots.txt")
+	if previous != nil {
+		logger.Debugf("Failed to fetch robots.txt, keeping the previous rules: %s", err)
+		return previous
+	}
+	logger.Debugf("Failed to fetch robots.txt, disallowing host: %s", err)
+	return DisallowAll()
+}
+
+func (c *Cache) download(robotsUrl string) (*Robots, error) {
+	req, err := http.NewRequest(http.MethodGet, robotsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return Parse(res.Body)
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return AllowAll(), nil
+	default:
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+}
@@ -0,0 +1,202 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSize is the amount of a robots.txt file that is parsed, as recommended by RFC 9309
+const maxSize = 500 * 1024
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// Group is the set of rules that apply to a list of user agents
+type Group struct {
+	agents     []string
+	rules      []rule
+	CrawlDelay time.Duration
+}
+
+// Robots is a parsed robots.txt file
+type Robots struct {
+	groups   []*Group
+	Sitemaps []string
+	// disallowAll is set when the file could not be retrieved because the
+	// server failed, in which case nothing may be crawled
+	disallowAll bool
+}
+
+// AllowAll returns rules that allow every path, used when a host has no robots.txt
+func AllowAll() *Robots {
+	return &Robots{}
+}
+
+// DisallowAll returns rules that refuse every path, used when robots.txt is unreachable
+func DisallowAll() *Robots {
+	return &Robots{disallowAll: true}
+}
+
+// Parse reads a robots.txt file. Unknown directives and malformed lines are ignored.
+func Parse(r io.Reader) (*Robots, error) {
+	robots := &Robots{}
+	var current *Group
+	// collectingAgents is true while consecutive user-agent lines build up a group
+	collectingAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxSize))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !collectingAgents {
+				current = &Group{}
+				robots.groups = append(robots.groups, current)
+				collectingAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+
+		case "allow", "disallow":
+			collectingAgents = false
+			// An empty disallow means everything is allowed
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+
+		case "crawl-delay":
+			collectingAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+				current.CrawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+
+		case "sitemap":
+			// Sitemap lines are not tied to any group
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+		}
+	}
+	return robots, scanner.Err()
+}
+
+// productToken returns the lowercase name part of a user agent, e.g. "web-crawler" for "web-crawler/1.0 (+info)"
+func productToken(userAgent string) string {
+	token := strings.ToLower(strings.TrimSpace(userAgent))
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	return token
+}
+
+// groupsFor returns the groups that apply to userAgent: all groups naming the
+// most specific matching agent, or the "*" groups when none name it
+func (r *Robots) groupsFor(userAgent string) []*Group {
+	token := productToken(userAgent)
+	var matched, wildcard []*Group
+	bestLen := 0
+	for _, group := range r.groups {
+		for _, agent := range group.agents {
+			switch {
+			case agent == "*":
+				wildcard = append(wildcard, group)
+			case token != "" && strings.HasPrefix(token, agent):
+				if len(agent) > bestLen {
+					matched = []*Group{group}
+					bestLen = len(agent)
+				} else if len(agent) == bestLen {
+					matched = append(matched, group)
+				}
+			}
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+	return wildcard
+}
+
+// Allowed reports whether userAgent may fetch path, which should include the
+// query string. The longest matching rule wins and allow wins ties.
+func (r *Robots) Allowed(userAgent string, path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+
+	allowed := true
+	longest := -1
+	for _, group := range r.groupsFor(userAgent) {
+		for _, rule := range group.rules {
+			if !matchPattern(rule.pattern, path) {
+				continue
+			}
+			if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+				longest = len(rule.pattern)
+				allowed = rule.allow
+			}
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the crawl delay requested for userAgent, 0 if none
+func (r *Robots) CrawlDelay(userAgent string) time.Duration {
+	var delay time.Duration
+	for _, group := range r.groupsFor(userAgent) {
+		if group.CrawlDelay > delay {
+			delay = group.CrawlDelay
+		}
+	}
+	return delay
+}
+
+// matchPattern matches a robots.txt path pattern where "*" matches any
+// sequence of characters and a trailing "$" anchors the end of the path
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	// The first part must be a prefix of the path
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(path, part)
+		}
+		idx := strings.Index(path, part)
+		if idx < 0 {
+			return false
+		}
+		path = path[idx+len(part):]
+	}
+	return !anchored || path == ""
+}
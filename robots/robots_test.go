@@ -0,0 +1,230 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# Comment line
+User-agent: *
+Disallow: /private/
+Allow: /private/public-page
+Disallow: /*.pdf$
+Crawl-delay: 1
+
+User-agent: web-crawler
+User-agent: other-bot
+Disallow: /no-crawlers
+Allow: /no-crawlers/except-this
+Crawl-delay: 5
+
+User-agent: blocked-bot
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+Sitemap: https://example.com/news-sitemap.xml
+`
+
+// TestParseAllowed tests longest-match precedence and wildcard handling
+func TestParseAllowed(t *testing.T) {
+	robots, err := Parse(strings.NewReader(testRobots))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"Wildcard group disallow", "some-bot", "/private/data", false},
+		{"Longer allow wins", "some-bot", "/private/public-page", true},
+		{"End anchored wildcard", "some-bot", "/docs/file.pdf", false},
+		{"End anchor does not match longer path", "some-bot", "/docs/file.pdf.html", true},
+		{"Unlisted path", "some-bot", "/about", true},
+		{"Specific group replaces wildcard group", "web-crawler/1.0", "/private/data", true},
+		{"Specific group disallow", "web-crawler/1.0", "/no-crawlers/page", false},
+		{"Specific group allow", "web-crawler/1.0", "/no-crawlers/except-this", true},
+		{"Second agent in group", "Other-Bot", "/no-crawlers", false},
+		{"Disallow everything", "blocked-bot", "/", false},
+		{"robots.txt always allowed", "blocked-bot", "/robots.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := robots.Allowed(tt.userAgent, tt.path); got != tt.allowed {
+				t.Errorf("Allowed(%q, %q) = %v, expected %v", tt.userAgent, tt.path, got, tt.allowed)
+			}
+		})
+	}
+}
+
+// TestParseCrawlDelayAndSitemaps tests Crawl-delay per group and global Sitemap lines
+func TestParseCrawlDelayAndSitemaps(t *testing.T) {
+	robots, _ := Parse(strings.NewReader(testRobots))
+
+	if delay := robots.CrawlDelay("web-crawler/1.0"); delay != 5*time.Second {
+		t.Errorf("Expected crawl delay 5s, got %v", delay)
+	}
+	if delay := robots.CrawlDelay("unknown"); delay != time.Second {
+		t.Errorf("Expected wildcard crawl delay 1s, got %v", delay)
+	}
+	if delay := robots.CrawlDelay("blocked-bot"); delay != 0 {
+		t.Errorf("Expected no crawl delay, got %v", delay)
+	}
+	if len(robots.Sitemaps) != 2 || robots.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("Unexpected sitemaps: %v", robots.Sitemaps)
+	}
+}
+
+// TestMatchPattern tests robots.txt path pattern matching
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish.html", false},
+		{"/fish*", "/fish/salmon", true},
+		{"/fish/", "/fish", false},
+		{"/*.php", "/folder/index.php?x=1", true},
+		{"/*.php$", "/index.php", true},
+		{"/*.php$", "/index.php?x=1", false},
+		{"/fish*.php", "/fishheads/catfish.php?p=1", true},
+		{"/", "/anything", true},
+		{"/exact$", "/exact", true},
+		{"/exact$", "/exactly", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path); got != tt.matches {
+			t.Errorf("matchPattern(%q, %q) = %v, expected %v", tt.pattern, tt.path, got, tt.matches)
+		}
+	}
+}
+
+// TestCacheFetchesOncePerHost tests that robots.txt is fetched once and reused
+func TestCacheFetchesOncePerHost(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+		}
+		if ua := r.Header.Get("User-Agent"); ua != "web-crawler/1.0" {
+			t.Errorf("Expected user agent to be sent, got %q", ua)
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+	}))
+	defer server.Close()
+
+	cache := NewCache(nil, "web-crawler/1.0")
+	allowedURL, _ := url.Parse(server.URL + "/page")
+	disallowedURL, _ := url.Parse(server.URL + "/admin/users")
+
+	done := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		go func() {
+			cache.Allowed(allowedURL)
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		<-done
+	}
+
+	if !cache.Allowed(allowedURL) {
+		t.Error("Expected /page to be allowed")
+	}
+	if cache.Allowed(disallowedURL) {
+		t.Error("Expected /admin/users to be disallowed")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", n)
+	}
+}
+
+// TestCacheStatusHandling tests that a missing robots.txt allows and a server error disallows
+func TestCacheStatusHandling(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		allowed    bool
+	}{
+		{"404 allows everything", http.StatusNotFound, true},
+		{"403 allows everything", http.StatusForbidden, true},
+		{"500 disallows everything", http.StatusInternalServerError, false},
+		{"503 disallows everything", http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			cache := NewCache(nil, "web-crawler/1.0")
+			pageURL, _ := url.Parse(server.URL + "/page")
+			if got := cache.Allowed(pageURL); got != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.allowed, got)
+			}
+		})
+	}
+}
+
+// TestCacheRetriesFailures tests that a failed fetch is only reused for the failure TTL
+func TestCacheRetriesFailures(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+	}))
+	defer server.Close()
+
+	cache := NewCache(nil, "web-crawler/1.0")
+	cache.ttl = 50 * time.Millisecond
+	cache.failureTTL = 50 * time.Millisecond
+	pageURL, _ := url.Parse(server.URL + "/page")
+	if cache.Allowed(pageURL) {
+		t.Error("Expected the host to be disallowed while robots.txt fails")
+	}
+	if delay := cache.RetryIn(pageURL); delay <= 0 || delay > 50*time.Millisecond {
+		t.Errorf("Expected robots.txt to be retried within the failure TTL, got %s", delay)
+	}
+
+	failing.Store(false)
+	if cache.Allowed(pageURL) {
+		t.Error("Expected the failure to be reused within the failure TTL")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !cache.Allowed(pageURL) {
+		t.Error("Expected robots.txt to be fetched again after the failure TTL")
+	}
+	if delay := cache.RetryIn(pageURL); delay != 0 {
+		t.Errorf("Expected no retry once robots.txt was fetched, got %s", delay)
+	}
+
+	// A later failure keeps the rules fetched before it
+	failing.Store(true)
+	time.Sleep(100 * time.Millisecond)
+	adminURL, _ := url.Parse(server.URL + "/admin")
+	if !cache.Allowed(pageURL) || cache.Allowed(adminURL) {
+		t.Error("Expected the previous rules to be kept when a refetch fails")
+	}
+	if delay := cache.RetryIn(pageURL); delay != 0 {
+		t.Errorf("Expected the previous rules to decide, got a retry in %s", delay)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+)
+
+// Seeds file formats
+const (
+	seedsText  = "txt"
+	seedsCSV   = "csv"
+	seedsJSONL = "jsonl"
+)
+
+// seedLine is a seed as written in a seeds file. Depth and Scope override
+// --depth and --scope for everything crawled from the seed.
+type seedLine struct {
+	Url   string `json:"url"`
+	Depth *int   `json:"depth"`
+	Scope string `json:"scope"`
+}
+
+// loadSeeds reads the seeds file at path, or stdin if path is "-". Lines
+// that are not valid seeds are skipped and returned as invalid, err is only
+// set when the file cannot be read.
+func loadSeeds(path string, format string, stdin io.Reader) (seeds []*frontier.Request, invalid []error, err error) {
+	name := path
+	r := stdin
+	if path == "-" {
+		name = "stdin"
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open seeds file: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	buffered := bufio.NewReader(r)
+	if format == "" {
+		format = detectSeedsFormat(path, buffered)
+	}
+	switch format {
+	case seedsText:
+		return readTextSeeds(name, buffered)
+	case seedsCSV:
+		return readCSVSeeds(name, buffered)
+	case seedsJSONL:
+		return readJSONSeeds(name, buffered)
+	}
+	return nil, nil, fmt.Errorf("unknown seeds format %q, expected one of %s, %s, %s", format, seedsText, seedsCSV, seedsJSONL)
+}
+
+// detectSeedsFormat guesses the format from the file extension, or from the
+// first line when the extension does not tell
+func detectSeedsFormat(path string, r *bufio.Reader) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return seedsCSV
+	case ".jsonl", ".ndjson", ".json":
+		return seedsJSONL
+	case ".txt":
+		return seedsText
+	}
+
+	data, _ := r.Peek(4096)
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0 || line[0] == '#':
+			continue
+		case line[0] == '{':
+			return seedsJSONL
+		case bytes.ContainsRune(line, ','):
+			return seedsCSV
+		}
+		break
+	}
+	return seedsText
+}
+
+// readTextSeeds reads one URL per line, skipping blank lines and # comments
+func readTextSeeds(name string, r io.Reader) ([]*frontier.Request, []error, error) {
+	var seeds []*frontier.Request
+	var invalid []error
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seed, err := newSeed(seedLine{Url: line})
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, lineNum, err))
+			continue
+		}
+		seeds = append(seeds, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read seeds: %w", err)
+	}
+	return seeds, invalid, nil
+}
+
+// readCSVSeeds reads url,depth,scope records. The depth and scope columns
+// are optional and may be left empty, and a header row naming a url column
+// may list the columns in any order.
+func readCSVSeeds(name string, r io.Reader) ([]*frontier.Request, []error, error) {
+	var seeds []*frontier.Request
+	var invalid []error
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"url": 0, "depth": 1, "scope": 2}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, parseErr.Line, parseErr.Err))
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read seeds: %w", err)
+		}
+		lineNum, _ := reader.FieldPos(0)
+
+		// A first row with a url column is a header
+		if first && slices.ContainsFunc(record, func(column string) bool {
+			return strings.EqualFold(strings.TrimSpace(column), "url")
+		}) {
+			columns = make(map[string]int, len(record))
+			for i, column := range record {
+				columns[strings.ToLower(strings.TrimSpace(column))] = i
+			}
+			continue
+		}
+
+		line := seedLine{Url: field(record, "url"), Scope: field(record, "scope")}
+		if depth := field(record, "depth"); depth != "" {
+			parsed, err := strconv.Atoi(depth)
+			if err != nil {
+				invalid = append(invalid, fmt.Errorf("%s:%d: invalid depth %q", name, lineNum, depth))
+				continue
+			}
+			line.Depth = &parsed
+		}
+		seed, err := newSeed(line)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, lineNum, err))
+			continue
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, invalid, nil
+}
+
+// readJSONSeeds reads one {"url": ..., "depth": ..., "scope": ...} object per line
+func readJSONSeeds(name string, r io.Reader) ([]*frontier.Request, []error, error) {
+	var seeds []*frontier.Request
+	var invalid []error
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var line seedLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, lineNum, err))
+			continue
+		}
+		seed, err := newSeed(line)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, lineNum, err))
+			continue
+		}
+		seeds = append(seeds, seed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read seeds: %w", err)
+	}
+	return seeds, invalid, nil
+}
+
+// newSeed validates a seed and turns it into a frontier request. As with
+// --depth, a depth of 0 means unlimited.
+func newSeed(line seedLine) (*frontier.Request, error) {
+	if line.Url == "" {
+		return nil, fmt.Errorf("missing URL")
+	}
+	u, err := url.Parse(line.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q", line.Url)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: expected an absolute http or https URL", line.Url)
+	}
+
+	seed := frontier.NewRequest(u)
+	if line.Depth != nil {
+		switch {
+		case *line.Depth < 0:
+			return nil, fmt.Errorf("invalid depth %d", *line.Depth)
+		case *line.Depth == 0:
+			seed.MaxDepth = -1
+		default:
+			seed.MaxDepth = *line.Depth
+		}
+	}
+	if line.Scope != "" {
+		if seed.Scope, err = frontier.ParseScopeKind(line.Scope); err != nil {
+			return nil, err
+		}
+	}
+	return seed, nil
+}
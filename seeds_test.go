@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Fardin-E/web_crawler.git/frontier"
+)
+
+// TestLoadSeeds tests reading seeds in every format, skipping invalid lines
+func TestLoadSeeds(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		expected []string
+		invalid  int
+	}{
+		{
+			name:     "text",
+			input:    "# sites\nhttps://a.com/\n\n  https://b.com/docs  \nnot a url\nftp://c.com/\n",
+			expected: []string{"https://a.com/ 0 ", "https://b.com/docs 0 "},
+			invalid:  2,
+		},
+		{
+			name:     "csv",
+			input:    "https://a.com/,2,same-host\nhttps://b.com/\nhttps://c.com/,x\nhttps://d.com/,0,galaxy\nhttps://e.com/,,seed-path\n",
+			expected: []string{"https://a.com/ 2 same-host", "https://b.com/ 0 ", "https://e.com/ 0 seed-path"},
+			invalid:  2,
+		},
+		{
+			name:     "csv header",
+			input:    "scope,url\nsame-domain,https://a.com/\n",
+			expected: []string{"https://a.com/ 0 same-domain"},
+		},
+		{
+			name:     "jsonl",
+			input:    "{\"url\":\"https://a.com/\",\"depth\":0}\n{\"url\":\"https://b.com/\",\"scope\":\"same-host\",\"depth\":4}\n{broken\n{\"depth\":1}\n",
+			expected: []string{"https://a.com/ -1 ", "https://b.com/ 4 same-host"},
+			invalid:  2,
+		},
+		{
+			name:     "explicit format",
+			format:   "txt",
+			input:    "https://a.com/?q=1,2\n",
+			expected: []string{"https://a.com/?q=1,2 0 "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seeds, invalid, err := loadSeeds("-", tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("loadSeeds failed: %v", err)
+			}
+			if len(invalid) != tt.invalid {
+				t.Errorf("Expected %d invalid lines, got %v", tt.invalid, invalid)
+			}
+			got := seedStrings(seeds)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected seeds %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestLoadSeedsFile tests that the format is detected from the file extension
+func TestLoadSeedsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seeds.csv")
+	os.WriteFile(path, []byte("url,depth\nhttps://a.com/,1\n"), 0644)
+
+	seeds, invalid, err := loadSeeds(path, "", nil)
+	if err != nil {
+		t.Fatalf("loadSeeds failed: %v", err)
+	}
+	if len(invalid) != 0 || len(seeds) != 1 || seeds[0].MaxDepth != 1 {
+		t.Errorf("Unexpected seeds %q, invalid %v", seedStrings(seeds), invalid)
+	}
+
+	if _, _, err := loadSeeds(filepath.Join(t.TempDir(), "missing.txt"), "", nil); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if _, _, err := loadSeeds(path, "xml", nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func seedStrings(seeds []*frontier.Request) []string {
+	got := []string{}
+	for _, seed := range seeds {
+		got = append(got, strings.Join([]string{seed.Url.String(), strconv.Itoa(seed.MaxDepth), string(seed.Scope)}, " "))
+	}
+	return got
+}
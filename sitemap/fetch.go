@@ -0,0 +1,72 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxSitemaps bounds how many sitemap files a single Fetch downloads, which
+// protects against huge or cyclic sitemap indexes
+const MaxSitemaps = 1000
+
+// Fetch downloads the sitemap at sitemapUrl and, for sitemap indexes, every
+// sitemap it references. Child sitemaps that fail are logged and skipped, only
+// a failure of the root sitemap is returned as an error.
+func Fetch(client *http.Client, userAgent string, sitemapUrl string) ([]Entry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	entries := []Entry{}
+	visited := map[string]bool{sitemapUrl: true}
+	queue := []string{sitemapUrl}
+	for len(queue) > 0 && len(visited) <= MaxSitemaps {
+		current := queue[0]
+		queue = queue[1:]
+
+		sitemap, err := download(client, userAgent, current)
+		if err != nil {
+			if current == sitemapUrl {
+				return nil, err
+			}
+			log.WithField("sitemap", current).Warnf("Skipping sitemap: %s", err)
+			continue
+		}
+		entries = append(entries, sitemap.Entries...)
+
+		base, _ := url.Parse(current)
+		for _, child := range sitemap.Sitemaps {
+			childUrl, err := base.Parse(child)
+			if err != nil || visited[childUrl.String()] {
+				continue
+			}
+			visited[childUrl.String()] = true
+			queue = append(queue, childUrl.String())
+		}
+	}
+	return entries, nil
+}
+
+func download(client *http.Client, userAgent string, sitemapUrl string) (*Sitemap, error) {
+	req, err := http.NewRequest(http.MethodGet, sitemapUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return Parse(res.Body)
+}
@@ -0,0 +1,119 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSize is the largest uncompressed sitemap the protocol allows
+const maxSize = 50 * 1024 * 1024
+
+// Entry is a single <url> of a sitemap
+type Entry struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	// Priority is between 0 and 1, the protocol default is 0.5
+	Priority float64
+}
+
+// Sitemap is a parsed sitemap file, either a urlset listing pages or a
+// sitemap index listing further sitemaps
+type Sitemap struct {
+	Entries []Entry
+	// Sitemaps are the child sitemap locations of a sitemap index
+	Sitemaps []string
+}
+
+type xmlUrl struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+type xmlDocument struct {
+	XMLName  xml.Name
+	Urls     []xmlUrl `xml:"url"`
+	Sitemaps []xmlUrl `xml:"sitemap"`
+}
+
+// Parse reads a sitemap or sitemap index, transparently decompressing gzip
+func Parse(r io.Reader) (*Sitemap, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(2)
+	var content io.Reader = buffered
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap: %w", err)
+		}
+		defer gz.Close()
+		content = gz
+	}
+
+	var doc xmlDocument
+	if err := xml.NewDecoder(io.LimitReader(content, maxSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap: %w", err)
+	}
+
+	sitemap := &Sitemap{}
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.Urls {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" {
+				continue
+			}
+			sitemap.Entries = append(sitemap.Entries, Entry{
+				Loc:        loc,
+				LastMod:    parseLastMod(u.LastMod),
+				ChangeFreq: strings.ToLower(strings.TrimSpace(u.ChangeFreq)),
+				Priority:   parsePriority(u.Priority),
+			})
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid sitemap: unexpected root element <%s>", doc.XMLName.Local)
+	}
+	return sitemap, nil
+}
+
+// lastModLayouts are the W3C datetime forms allowed in <lastmod>
+var lastModLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func parseLastMod(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parsePriority(value string) float64 {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || priority < 0 || priority > 1 {
+		return 0.5
+	}
+	return priority
+}
@@ -0,0 +1,131 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testUrlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+    <lastmod>2024-05-01</lastmod>
+    <changefreq>Daily</changefreq>
+    <priority>1.0</priority>
+  </url>
+  <url>
+    <loc> https://example.com/about </loc>
+    <lastmod>2024-05-01T10:30:00+02:00</lastmod>
+  </url>
+  <url>
+    <loc></loc>
+  </url>
+</urlset>`
+
+// TestParseUrlset tests parsing entries with lastmod, changefreq and priority
+func TestParseUrlset(t *testing.T) {
+	sitemap, err := Parse(strings.NewReader(testUrlset))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(sitemap.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(sitemap.Entries))
+	}
+
+	first := sitemap.Entries[0]
+	if first.Loc != "https://example.com/" || first.ChangeFreq != "daily" || first.Priority != 1.0 {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if !first.LastMod.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected lastmod: %v", first.LastMod)
+	}
+
+	second := sitemap.Entries[1]
+	if second.Loc != "https://example.com/about" {
+		t.Errorf("Expected loc to be trimmed, got %q", second.Loc)
+	}
+	if second.Priority != 0.5 {
+		t.Errorf("Expected default priority 0.5, got %v", second.Priority)
+	}
+	if second.LastMod.UTC() != time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC) {
+		t.Errorf("Unexpected lastmod: %v", second.LastMod)
+	}
+}
+
+// TestParseIndexAndGzip tests sitemap indexes and gzip-compressed sitemaps
+func TestParseIndexAndGzip(t *testing.T) {
+	index := `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap1.xml.gz</loc></sitemap>
+  <sitemap><loc>https://example.com/sitemap2.xml</loc></sitemap>
+</sitemapindex>`
+
+	sitemap, err := Parse(bytes.NewReader(gzipped(t, index)))
+	if err != nil {
+		t.Fatalf("Failed to parse gzipped index: %v", err)
+	}
+	if len(sitemap.Sitemaps) != 2 || len(sitemap.Entries) != 0 {
+		t.Errorf("Unexpected index contents: %+v", sitemap)
+	}
+
+	if _, err := Parse(strings.NewReader("<html><body>Not a sitemap</body></html>")); err == nil {
+		t.Error("Expected error for non-sitemap document")
+	}
+}
+
+// TestFetchFollowsIndex tests that Fetch walks sitemap indexes, including cycles
+func TestFetchFollowsIndex(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml":
+			w.Write([]byte(`<sitemapindex>
+  <sitemap><loc>` + server.URL + `/pages.xml.gz</loc></sitemap>
+  <sitemap><loc>/posts.xml</loc></sitemap>
+  <sitemap><loc>/missing.xml</loc></sitemap>
+  <sitemap><loc>/sitemap_index.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/pages.xml.gz":
+			w.Write(gzipped(t, `<urlset><url><loc>https://example.com/page</loc></url></urlset>`))
+		case "/posts.xml":
+			w.Write([]byte(`<urlset><url><loc>https://example.com/post</loc></url></urlset>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	entries, err := Fetch(nil, "web-crawler/1.0", server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	locs := []string{}
+	for _, entry := range entries {
+		locs = append(locs, entry.Loc)
+	}
+	sort.Strings(locs)
+	if strings.Join(locs, ",") != "https://example.com/page,https://example.com/post" {
+		t.Errorf("Unexpected entries: %v", locs)
+	}
+
+	if _, err := Fetch(nil, "", server.URL+"/missing.xml"); err == nil {
+		t.Error("Expected error when the root sitemap is missing")
+	}
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to gzip: %v", err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
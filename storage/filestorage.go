@@ -25,14 +25,7 @@ func NewFileStorage(root string) (*FileStorage, error) {
 
 func (s *FileStorage) Get(filePath string) (string, error) {
 	fullPath := path.Join(s.root, filePath)
-	file, err := os.Open(fullPath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	content := make([]byte, 10000)
-	_, err = file.Read(content)
+	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return "", err
 	}